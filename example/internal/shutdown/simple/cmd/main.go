@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"github.com/oomamontov/grace/example/internal/shutdown/simple/config"
 	"github.com/oomamontov/grace/example/internal/shutdown/simple/service"
 	"github.com/oomamontov/grace/example/internal/shutdown/simple/storage/caching"
@@ -25,11 +24,10 @@ func main() {
 	grpcServer := grpc.New(cfg.Transport.GRPC, svc, log)
 
 	builder := shutdown.New().WithDefaultValues().
+		WithLogger(log).
 		Register(kvStorage, rStorage).
 		Register(cache). // cache is depending on rStorage, so rStorage should be initialized beforehand
 		Register(svc).
 		Register(httpServer, grpcServer) // servers are independent, so they could be initialized and stopped concurrently
-	if err := builder.Run(context.Background()); err != nil {
-		log.With(slog.String("error", err.Error())).Error("error running application")
-	}
+	shutdown.Main(builder, shutdown.WithMainLogger(log))
 }
@@ -0,0 +1,1017 @@
+package shutdown
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oomamontov/grace/pkg/optional"
+	"github.com/oomamontov/grace/shutdown/task"
+	"golang.org/x/sync/errgroup"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State represents lifecycle stage of the App.
+type State int32
+
+const (
+	StateInitializing State = iota
+	StateRunning
+	StateStopping
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateInitializing:
+		return "initializing"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("State(%d)", int32(s))
+	}
+}
+
+// App is a handle of the application started with Config.Start.
+// App is goroutine-safe.
+type App struct {
+	cfg      Config
+	graph    graph
+	observer Observer
+	start    time.Time
+	state    atomic.Int32
+	started  atomic.Bool // set once all layers are initialized
+
+	signals  chan os.Signal
+	stopReq  chan struct{} // closed on Shutdown
+	stopOnce sync.Once
+	reason   string // set before stopReq is closed
+
+	mu    sync.Mutex
+	cause error
+
+	health      healthChecks
+	degradation degradation
+
+	done chan struct{} // closed after err is set
+	err  error
+}
+
+// Start runs Init and then Run on registered runners in background and returns immediately.
+// Provided context might be used to stop initialization, but not running runners.
+// See Config.Run for details.
+func (c Config) Start(ctx context.Context) *App {
+	a := &App{
+		cfg:      c,
+		observer: c.newObserver(),
+		start:    time.Now(),
+		signals:  make(chan os.Signal, 1),
+		stopReq:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	signal.Notify(a.signals, c.signals.GetOrDefault()...)
+	go func() {
+		defer close(a.done)
+		defer signal.Stop(a.signals)
+		a.err = a.run(ctx)
+		a.state.Store(int32(StateStopped))
+	}()
+	return a
+}
+
+// Shutdown requests graceful shutdown of the application and returns immediately.
+// Shutdown requested during initialization cancels it, see Config.Run.
+// Only the first call has effect.
+func (a *App) Shutdown(reason string) {
+	a.stopOnce.Do(func() {
+		a.reason = reason
+		close(a.stopReq)
+	})
+}
+
+// Wait waits for the application to stop and returns the resulting error.
+// If a runner has panicked and re-panic is enabled, see Config.WithRepanic, Wait panics with task.PanicError.
+func (a *App) Wait() error {
+	<-a.done
+	if a.cfg.repanic.GetOrDefault() {
+		var panicErr task.PanicError
+		if errors.As(a.err, &panicErr) {
+			panic(panicErr)
+		}
+	}
+	return a.err
+}
+
+// Done returns channel closed after the application is stopped.
+func (a *App) Done() <-chan struct{} {
+	return a.done
+}
+
+// Ready reports whether the application is running and ready to serve.
+// It turns false as soon as shutdown is requested, before pre-stop delay and stopping of any layer.
+func (a *App) Ready() bool {
+	select {
+	case <-a.stopReq:
+		return false
+	default:
+		return a.State() == StateRunning
+	}
+}
+
+// State returns current lifecycle stage of the application.
+func (a *App) State() State {
+	return State(a.state.Load())
+}
+
+// Cause returns the cause of shutdown: SignalReceived, Requested or TaskFailed.
+// Returns nil if shutdown has not started yet.
+func (a *App) Cause() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cause
+}
+
+// layerError returns inner error of the idx-th layer occurred in phase wrapped into LayerError.
+func (a *App) layerError(idx int, phase Phase, inner error) LayerError {
+	return LayerError{
+		Name:           a.cfg.layers[idx].name,
+		Index:          idx,
+		Phase:          phase,
+		DuringShutdown: a.Cause() != nil,
+		Elapsed:        time.Since(a.start),
+		Inner:          inner,
+	}
+}
+
+// backgroundTaskError returns inner error of a background task of the idx-th layer occurred in phase
+// wrapped into BackgroundTaskError.
+func (a *App) backgroundTaskError(idx int, phase Phase, inner error) BackgroundTaskError {
+	return BackgroundTaskError{
+		Layer:          a.cfg.layers[idx].name,
+		Index:          idx,
+		Phase:          phase,
+		DuringShutdown: a.Cause() != nil,
+		Elapsed:        time.Since(a.start),
+		Inner:          inner,
+	}
+}
+
+// runError returns inner error of the application occurred in phase wrapped into RunError.
+func (a *App) runError(phase Phase, inner error) RunError {
+	return RunError{
+		Phase:   phase,
+		Elapsed: time.Since(a.start),
+		Inner:   inner,
+		Cause:   a.Cause(),
+	}
+}
+
+func (a *App) setStopping(cause error) {
+	a.mu.Lock()
+	a.cause = cause
+	a.mu.Unlock()
+	a.state.Store(int32(StateStopping))
+	a.observer.ShutdownRequested(cause)
+}
+
+// layerRun holds state of a single layer during Run stage.
+type layerRun struct {
+	app         *App
+	index       int // index of the layer in app.cfg.layers
+	layer       Layer
+	cancel      context.CancelCauseFunc
+	gate        chan struct{}             // closed when tasks may start Run
+	ready       []chan struct{}           // closed after task signals readiness; indexed as done
+	done        []chan struct{}           // closed after task returns; indexed as layer.allTasks
+	runErrs     []error                   // set before done is closed; indexed as done
+	cancelTasks []context.CancelCauseFunc // indexed as done
+
+	mu       sync.Mutex
+	stopErrs []error
+}
+
+// isRunning reports whether the i-th task has not returned yet.
+func (lr *layerRun) isRunning(i int) bool {
+	select {
+	case <-lr.done[i]:
+		return false
+	default:
+		return true
+	}
+}
+
+// stopStages groups task indexes by stop order: tasks with greater stop priority are stopped first,
+// main tasks are stopped before background tasks of the same priority.
+func (lr *layerRun) stopStages() [][]int {
+	type stopKey struct {
+		priority   int
+		background bool
+	}
+	key := func(i int) stopKey {
+		return stopKey{
+			priority:   lr.layer.taskAt(i).StopPriority(),
+			background: i >= len(lr.layer.tasks),
+		}
+	}
+	indexes := make([]int, len(lr.done))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(i, j int) int {
+		ki, kj := key(i), key(j)
+		if ki.priority != kj.priority {
+			return cmp.Compare(kj.priority, ki.priority)
+		}
+		if ki.background != kj.background {
+			if kj.background {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+
+	var stages [][]int
+	for i, idx := range indexes {
+		if i == 0 || key(idx) != key(indexes[i-1]) {
+			stages = append(stages, nil)
+		}
+		stages[len(stages)-1] = append(stages[len(stages)-1], idx)
+	}
+	return stages
+}
+
+// stop stops tasks of the layer in stages, see stopStages, and returns immediately.
+// Each stage calls Stop on running tasks, cancels context of each task after its Stop returns
+// and waits for the tasks and their Stop calls to return before the next stage starts.
+// Returned channel is closed after all stages are finished.
+// If ctx is done before all stages are finished, remaining tasks are cancelled at once
+// and returned channel is never closed.
+func (lr *layerRun) stop(ctx context.Context, cause error) <-chan struct{} {
+	observer := lr.app.observer
+	observer.LayerStopStarted(lr.app.cfg.layerInfo(lr.index))
+	stages := lr.stopStages()
+	stopped := make(chan struct{})
+	go func() {
+		for s, stage := range stages {
+			starts := make([]time.Time, len(stage))
+			stopRes := make([]chan error, len(stage))
+			for k, i := range stage {
+				starts[k] = time.Now()
+				stopRes[k] = make(chan error, 1)
+				if !lr.isRunning(i) {
+					lr.cancelTasks[i](cause)
+					stopRes[k] <- nil
+					continue
+				}
+				go func() {
+					stopCtx := ctx
+					if d, ok := lr.app.cfg.taskStopTimeout.Get(); ok {
+						var cancel context.CancelFunc
+						stopCtx, cancel = context.WithTimeout(ctx, d)
+						defer cancel()
+					}
+					err := lr.layer.taskAt(i).Stop(stopCtx)
+					if err != nil {
+						lr.mu.Lock()
+						lr.stopErrs = append(lr.stopErrs, lr.layer.taskError(i, err))
+						lr.mu.Unlock()
+					}
+					lr.cancelTasks[i](cause)
+					stopRes[k] <- err
+				}()
+			}
+			for k, i := range stage {
+				var stopErr error
+				select {
+				case <-lr.done[i]:
+					stopErr = <-stopRes[k]
+				case <-ctx.Done():
+					for _, stage := range stages[s:] {
+						for _, i := range stage {
+							lr.cancelTasks[i](cause)
+						}
+					}
+					return
+				}
+				taskErr := lr.runErrs[i]
+				if stopErr != nil {
+					taskErr = errors.Join(stopErr, taskErr)
+				}
+				observer.TaskStopped(lr.app.cfg.taskInfo(lr.index, i), time.Since(starts[k]), taskErr)
+			}
+		}
+		close(stopped)
+	}()
+	return stopped
+}
+
+// stopError returns Stop errors reported so far.
+func (lr *layerRun) stopError() error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if len(lr.stopErrs) == 0 {
+		return nil
+	}
+	return lr.app.layerError(lr.index, PhaseStop, errors.Join(lr.stopErrs...))
+}
+
+// runningTasks returns names of tasks that have not returned yet.
+func (lr *layerRun) runningTasks() []string {
+	var res []string
+	for i := range lr.done {
+		if lr.isRunning(i) {
+			res = append(res, lr.layer.taskName(i))
+		}
+	}
+	return res
+}
+
+// timer returns channel firing on deadline and function releasing its resources.
+// If deadline is not set, returned channel never fires.
+func timer(deadline optional.Value[time.Time]) (<-chan time.Time, func() bool) {
+	d, ok := deadline.Get()
+	if !ok {
+		return nil, func() bool { return false }
+	}
+	t := time.NewTimer(time.Until(d))
+	return t.C, t.Stop
+}
+
+func (a *App) run(ctx context.Context) error {
+	g, err := a.cfg.resolveGraph()
+	if err != nil {
+		return a.runError(PhaseInit, err)
+	}
+	a.graph = g
+
+	initialized, err := a.initLayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	// ctx cancellation does nothing from now on
+
+	runCtx, cancelRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRun()
+
+	var (
+		mu        sync.Mutex
+		runErrs   []error
+		failCause error
+	)
+	failed := make(chan struct{}) // closed on the first task failure
+	fail := func(err error, cause TaskFailed) {
+		mu.Lock()
+		defer mu.Unlock()
+		runErrs = append(runErrs, err)
+		if failCause == nil {
+			failCause = cause
+			close(failed)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var starting sync.WaitGroup
+	abort := make(chan struct{}) // closed when shutdown starts; tasks not started yet are not started
+	layers := make([]*layerRun, 0, len(a.cfg.layers))
+	for i := range a.cfg.layers {
+		layers = append(layers, a.startLayer(runCtx, i, initialized[i], abort, &starting, fail))
+	}
+	allReady := a.openGates(layers, abort, fail)
+	go func() {
+		select {
+		case <-allReady:
+		case <-abort:
+			return
+		}
+		if a.state.CompareAndSwap(int32(StateInitializing), int32(StateRunning)) {
+			a.started.Store(true)
+			a.observer.AppReady(time.Since(a.start))
+			a.watchHealth(runCtx, done)
+		}
+	}()
+
+	var received []os.Signal // interrupt signals received
+	select {
+	case sig := <-a.signals:
+		received = append(received, sig)
+		a.setStopping(SignalReceived{Signal: sig})
+		if sig := a.preStopDelay(failed); sig != nil {
+			received = append(received, sig)
+		}
+	case <-a.stopReq:
+		a.setStopping(Requested{Reason: a.reason})
+		if sig := a.preStopDelay(failed); sig != nil {
+			received = append(received, sig)
+		}
+	case <-failed:
+		mu.Lock()
+		a.setStopping(failCause)
+		mu.Unlock()
+	}
+	close(abort)
+	starting.Wait()
+	stopErrs, forced := a.stopLayers(layers, a.watchForceStop(received, done))
+
+	mu.Lock()
+	err = errors.Join(slices.Concat(runErrs, a.degradedErrors(), stopErrs)...)
+	phase := PhaseRun
+	if errors.As(failCause, new(ReadyTimeoutError)) {
+		phase = PhaseInit // the application has failed to start
+	}
+	mu.Unlock()
+	if err != nil {
+		err = a.runError(phase, err)
+	}
+	if forced {
+		if hook, ok := a.cfg.forceStopHook.Get(); ok {
+			hook(err)
+		}
+		if code, ok := a.cfg.forceExitCode.Get(); ok {
+			os.Exit(code)
+		}
+	}
+	return err
+}
+
+// preStopDelay waits for pre-stop delay, if configured, before layers are stopped.
+// Task failure reported by closing failed ends the delay early, and so does interrupt signal,
+// which is returned to be counted to force stop.
+func (a *App) preStopDelay(failed <-chan struct{}) os.Signal {
+	d, ok := a.cfg.preStopDelay.Get()
+	if !ok || d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case sig := <-a.signals:
+		return sig
+	case <-failed:
+	}
+	return nil
+}
+
+// initLayers runs Init on registered layers in order of their dependencies.
+// Interrupt signal or shutdown request cancels initialization.
+// Returns initialization status of tasks indexed by layer and task, see initTasks.
+// If initialization fails or is interrupted, already initialized tasks are rolled back.
+func (a *App) initLayers(ctx context.Context) ([][]bool, error) {
+	initCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopWatch := a.watchInitInterrupt(cancel)
+	order, initialized, err := a.initTasks(initCtx)
+	stopWatch()
+
+	if cause := a.Cause(); cause != nil {
+		interruptedErr := InitInterruptedError{Cause: cause}
+		if errors.As(err, new(LayerError)) {
+			err = errors.Join(interruptedErr, err)
+		} else {
+			err = interruptedErr
+		}
+	}
+	if err == nil {
+		err = ctx.Err() // do not run if context is cancelled before goroutines start
+	}
+	if err != nil {
+		return nil, a.rollback(ctx, order, initialized, err)
+	}
+	return initialized, nil
+}
+
+// watchInitInterrupt cancels initialization on interrupt signal or shutdown request
+// and returns function stopping the watch. After the returned function is called,
+// App.Cause reports whether initialization has been interrupted.
+func (a *App) watchInitInterrupt(cancel context.CancelCauseFunc) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var cause error
+		select {
+		case sig := <-a.signals:
+			cause = SignalReceived{Signal: sig}
+		case <-a.stopReq:
+			cause = Requested{Reason: a.reason}
+		case <-done:
+			return
+		}
+		a.setStopping(cause)
+		cancel(cause)
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// initTasks runs Init on registered layers, each layer as soon as all its dependencies are initialized.
+// Returns indexes of layers which ran Init in order of completion and initialization status of their tasks,
+// indexed as layerRun.done.
+// Layers exceeding their init timeout or startup timeout are abandoned and reported with StartupTimeoutError.
+func (a *App) initTasks(ctx context.Context) (order []int, initialized [][]bool, err error) {
+	initCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var startupDeadline optional.Value[time.Time]
+	if d, ok := a.cfg.startupTimeout.Get(); ok {
+		startupDeadline.Set(time.Now().Add(d))
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	initialized = make([][]bool, len(a.cfg.layers))
+	ready := make([]chan struct{}, len(a.cfg.layers)) // closed after successful Init of the layer
+	for i := range ready {
+		ready[i] = make(chan struct{})
+	}
+	for i, layer := range a.cfg.layers {
+		wg.Go(func() {
+			for _, dep := range a.graph.deps[i] {
+				select {
+				case <-ready[dep]:
+				case <-initCtx.Done():
+					return
+				}
+			}
+			if initCtx.Err() != nil { // do not run Init if context is cancelled
+				return
+			}
+
+			deadline := startupDeadline
+			if d, ok := layer.initTimeout.Get(); ok {
+				if sd, ok := deadline.Get(); !ok || time.Now().Add(d).Before(sd) {
+					deadline.Set(time.Now().Add(d))
+				}
+			}
+			layerStart := time.Now()
+			a.observer.LayerInitStarted(a.cfg.layerInfo(i))
+			ok, layerErr := a.initLayer(initCtx, i, deadline)
+			a.observer.LayerInitFinished(a.cfg.layerInfo(i), time.Since(layerStart), layerErr)
+
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, i)
+			initialized[i] = ok
+			if layerErr != nil {
+				errs = append(errs, a.layerError(i, PhaseInit, layerErr))
+				cancel()
+				return
+			}
+			close(ready[i])
+		})
+	}
+	wg.Wait()
+
+	err = errors.Join(errs...)
+	if err == nil && len(order) < len(a.cfg.layers) {
+		err = ctx.Err()
+	}
+	return order, initialized, err
+}
+
+// initLayer runs Init on tasks of the idx-th layer in parallel and returns initialization status of each task.
+// Errors of all failed tasks are joined, errors of tasks cancelled because of the first failure are omitted.
+// If deadline is exceeded, the layer is abandoned: tasks still initializing are cancelled and not waited for.
+func (a *App) initLayer(ctx context.Context, idx int, deadline optional.Value[time.Time]) ([]bool, error) {
+	layer := a.cfg.layers[idx]
+	n := len(layer.tasks) + len(layer.backgroundTasks)
+	ok := make([]atomic.Bool, n)
+	initializing := make([]atomic.Bool, n)
+	var (
+		abandoned  atomic.Bool
+		mu         sync.Mutex
+		errs       []error // errors of failed tasks
+		cancelErrs []error // errors of tasks cancelled because of other failures or interruption
+	)
+	layerCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	initEg, egCtx := errgroup.WithContext(layerCtx)
+	for i, t := range layer.allTasks() {
+		initializing[i].Store(true)
+		initEg.Go(func() error {
+			defer initializing[i].Store(false)
+			start := time.Now()
+			err := t.Init(egCtx)
+			if !abandoned.Load() {
+				a.observer.TaskInitFinished(a.cfg.taskInfo(idx, i), time.Since(start), err)
+			}
+			if err != nil && t.Criticality() == task.Optional {
+				a.degrade(idx, i, PhaseInit, err) // retried in background, see runOptional
+				return nil
+			}
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if errors.Is(err, context.Canceled) && egCtx.Err() != nil {
+					cancelErrs = append(cancelErrs, layer.taskError(i, err))
+				} else {
+					errs = append(errs, layer.taskError(i, err))
+				}
+				return err
+			}
+			ok[i].Store(true)
+			return nil
+		})
+	}
+	res := make(chan error, 1)
+	go func() {
+		_ = initEg.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		if len(errs) == 0 {
+			errs = cancelErrs
+		}
+		res <- errors.Join(errs...)
+	}()
+
+	timeout, stopTimer := timer(deadline)
+	defer stopTimer()
+	var err error
+	select {
+	case err = <-res:
+	case <-timeout:
+		abandoned.Store(true)
+		var tasks []string
+		for i := range initializing {
+			if initializing[i].Load() {
+				tasks = append(tasks, layer.taskName(i))
+			}
+		}
+		err = StartupTimeoutError{Tasks: tasks}
+		cancel(err)
+	}
+
+	status := make([]bool, n)
+	for i := range ok {
+		status[i] = ok[i].Load()
+	}
+	return status, err
+}
+
+// rollback calls Close on initialized tasks in reverse order of layers initialization
+// and returns err joined with Close errors and failures of optional tasks.
+// Tasks within a single layer are closed in parallel. Closing is limited by shutdown timeout.
+func (a *App) rollback(ctx context.Context, order []int, initialized [][]bool, err error) error {
+	closeCtx := context.WithoutCancel(ctx)
+	if d, ok := a.cfg.shutdownTimeout.Get(); ok {
+		var cancel context.CancelFunc
+		closeCtx, cancel = context.WithTimeout(closeCtx, d)
+		defer cancel()
+	}
+
+	errs := []error{err}
+	for _, i := range slices.Backward(order) {
+		layer := a.cfg.layers[i]
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			layerErrs []error
+		)
+		for j, t := range layer.allTasks() {
+			if !initialized[i][j] {
+				continue
+			}
+			wg.Go(func() {
+				if err := t.Close(closeCtx); err != nil {
+					mu.Lock()
+					defer mu.Unlock()
+					layerErrs = append(layerErrs, layer.taskError(j, err))
+				}
+			})
+		}
+		wg.Wait()
+		if len(layerErrs) > 0 {
+			errs = append(errs, a.layerError(i, PhaseRollback, errors.Join(layerErrs...)))
+		}
+	}
+	errs = append(errs, a.degradedErrors()...)
+	return a.runError(PhaseInit, errors.Join(errs...))
+}
+
+// startLayer starts all tasks of the idx-th layer. Tasks start Run only after the layer gate is opened,
+// see openGates, or return immediately if abort is closed before that. Task errors are reported to fail,
+// except for optional tasks, which are run with runOptional, so their initialization status is required.
+// Returned layer is added to starting, which is done after each task starts Run or returns.
+func (a *App) startLayer(
+	ctx context.Context,
+	idx int,
+	initialized []bool,
+	abort <-chan struct{},
+	starting *sync.WaitGroup,
+	fail func(error, TaskFailed),
+) *layerRun {
+	layer := a.cfg.layers[idx]
+	n := len(layer.tasks) + len(layer.backgroundTasks)
+	layerCtx, cancel := context.WithCancelCause(ctx)
+	lr := &layerRun{
+		app:         a,
+		index:       idx,
+		layer:       layer,
+		cancel:      cancel,
+		gate:        make(chan struct{}),
+		ready:       make([]chan struct{}, n),
+		done:        make([]chan struct{}, n),
+		runErrs:     make([]error, n),
+		cancelTasks: make([]context.CancelCauseFunc, n),
+	}
+
+	for i, t := range layer.allTasks() {
+		taskCtx, cancelTask := context.WithCancelCause(layerCtx)
+		lr.cancelTasks[i] = cancelTask
+		lr.ready[i] = make(chan struct{})
+		lr.done[i] = make(chan struct{})
+		var readyOnce sync.Once
+		taskCtx = task.ContextWithReadyFunc(taskCtx, func() {
+			readyOnce.Do(func() {
+				a.observer.TaskReady(a.cfg.taskInfo(idx, i))
+				close(lr.ready[i])
+			})
+		})
+		taskCtx = task.ContextWithRestartFunc(taskCtx, func(restart int, err error) {
+			a.observer.TaskRestarted(a.cfg.taskInfo(idx, i), restart, err)
+		})
+		background := i >= len(layer.tasks)
+		starting.Add(1)
+		go func() {
+			defer close(lr.done[i])
+			started := lr.waitGate(abort)
+			starting.Done()
+			if !started {
+				return
+			}
+			if t.Criticality() == task.Optional {
+				lr.runErrs[i] = a.runOptional(taskCtx, idx, i, t, initialized[i])
+				return
+			}
+			a.observer.TaskRunStarted(a.cfg.taskInfo(idx, i))
+			err := t.Run(taskCtx)
+			lr.runErrs[i] = err
+			if err == nil {
+				return
+			}
+			cause := TaskFailed{Layer: layer.name, Task: lr.layer.taskName(i), Err: err}
+			if !background {
+				fail(a.layerError(idx, PhaseRun, layer.taskError(i, err)), cause)
+				return
+			}
+			// exceeded restart limit escalates to shutdown even if background tasks are fallible
+			if !a.cfg.fallibleBackgroundTasks.GetOrDefault() || errors.As(err, new(task.RestartLimitError)) {
+				fail(a.layerError(idx, PhaseRun, a.backgroundTaskError(idx, PhaseRun, layer.taskError(i, err))), cause)
+			}
+		}()
+	}
+
+	return lr
+}
+
+// waitGate waits for the layer gate to open and reports whether tasks should start Run.
+// Gate opened before abort wins, so tasks of a layer are started if tasks of its dependents might have been.
+func (lr *layerRun) waitGate(abort <-chan struct{}) bool {
+	select {
+	case <-lr.gate:
+		return true
+	case <-abort:
+		select {
+		case <-lr.gate:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// openGates opens gate of each layer as soon as all its dependencies are ready, and returns immediately.
+// Layer is ready once its gate is opened or, if the layer awaits readiness, once each of its tasks
+// signals readiness or returns. Layers exceeding their ready timeout are reported to fail with ReadyTimeoutError.
+// Returned channel is closed after all layers are ready. Gates are not opened after abort is closed.
+func (a *App) openGates(layers []*layerRun, abort <-chan struct{}, fail func(error, TaskFailed)) <-chan struct{} {
+	ready := make([]chan struct{}, len(layers))
+	for i := range ready {
+		ready[i] = make(chan struct{})
+	}
+	for i, lr := range layers {
+		go func() {
+			for _, dep := range a.graph.deps[i] {
+				select {
+				case <-ready[dep]:
+				case <-abort:
+					return
+				}
+			}
+			close(lr.gate)
+			if lr.layer.awaitReady && !lr.awaitReady(abort, fail) {
+				return
+			}
+			close(ready[i])
+		}()
+	}
+
+	allReady := make(chan struct{})
+	go func() {
+		for _, r := range ready {
+			select {
+			case <-r:
+			case <-abort:
+				return
+			}
+		}
+		close(allReady)
+	}()
+	return allReady
+}
+
+// awaitReady waits for each task of the layer to signal readiness or return and reports whether the layer is ready.
+func (lr *layerRun) awaitReady(abort <-chan struct{}, fail func(error, TaskFailed)) bool {
+	var deadline optional.Value[time.Time]
+	if d, ok := lr.layer.readyTimeout.Get(); ok {
+		deadline.Set(time.Now().Add(d))
+	}
+	timeout, stopTimer := timer(deadline)
+	defer stopTimer()
+	for i := range lr.ready {
+		if lr.layer.taskAt(i).Criticality() == task.Optional {
+			continue
+		}
+		select {
+		case <-lr.ready[i]:
+		case <-lr.done[i]:
+		case <-abort:
+			return false
+		case <-timeout:
+			var tasks []string
+			for i := range lr.ready {
+				if lr.layer.taskAt(i).Criticality() == task.Optional {
+					continue
+				}
+				select {
+				case <-lr.ready[i]:
+				case <-lr.done[i]:
+				default:
+					tasks = append(tasks, lr.layer.taskName(i))
+				}
+			}
+			if len(tasks) == 0 { // remaining tasks became ready along with the timeout
+				return true
+			}
+			err := ReadyTimeoutError{Tasks: tasks}
+			fail(lr.app.layerError(lr.index, PhaseInit, err), TaskFailed{Layer: lr.layer.name, Task: tasks[0], Err: err})
+			return false
+		}
+	}
+	return true
+}
+
+// watchForceStop counts interrupt signals, including already received ones,
+// and returns channel receiving the signal on which shutdown should be forced.
+// Returned channel fires at once if enough signals are already received
+// and never fires if force stop is not configured.
+func (a *App) watchForceStop(received []os.Signal, done <-chan struct{}) <-chan os.Signal {
+	n, ok := a.cfg.forceStopSignals.Get()
+	if !ok || n <= 0 {
+		return nil
+	}
+	force := make(chan os.Signal, 1)
+	if len(received) >= n {
+		force <- received[n-1]
+		return force
+	}
+	count := len(received)
+	go func() {
+		for {
+			select {
+			case sig := <-a.signals:
+				count++
+				if count >= n {
+					force <- sig
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return force
+}
+
+// stopLayers stops layers in reverse order of their dependencies with the cause of shutdown:
+// each layer is stopped after all layers depending on it are stopped, independent layers are stopped concurrently.
+// Stop of each task is called with context limited by stop timeout of its layer and shutdown timeout.
+// Layers exceeding their stop timeout or shutdown timeout are abandoned and reported with StopTimeoutError.
+// If force fires, all remaining layers are cancelled at once, reported with ForcedStopError and not waited for.
+func (a *App) stopLayers(layers []*layerRun, force <-chan os.Signal) (errs []error, forced bool) {
+	cause := a.Cause()
+	forceCtx, cancelForce := context.WithCancel(context.Background())
+	defer cancelForce()
+	var shutdownDeadline optional.Value[time.Time]
+	if d, ok := a.cfg.shutdownTimeout.Get(); ok {
+		shutdownDeadline.Set(time.Now().Add(d))
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		finished = make([]bool, len(layers))
+	)
+	stopped := make([]chan struct{}, len(layers)) // closed after the layer is stopped or abandoned
+	for i := range stopped {
+		stopped[i] = make(chan struct{})
+	}
+	for i, lr := range layers {
+		wg.Go(func() {
+			for _, dep := range a.graph.dependents[i] {
+				select {
+				case <-stopped[dep]:
+				case <-forceCtx.Done():
+					return
+				}
+			}
+
+			layerStart := time.Now()
+			deadline := shutdownDeadline
+			if d, ok := lr.layer.stopTimeout.Get(); ok {
+				if sd, ok := deadline.Get(); !ok || layerStart.Add(d).Before(sd) {
+					deadline.Set(layerStart.Add(d))
+				}
+			}
+			stopCtx := forceCtx
+			if d, ok := deadline.Get(); ok {
+				var cancel context.CancelFunc
+				stopCtx, cancel = context.WithDeadline(forceCtx, d)
+				defer cancel()
+			}
+			layerStopped := lr.stop(stopCtx, cause)
+
+			timeout, stopTimer := timer(deadline)
+			defer stopTimer()
+			var timeoutErr error
+			select {
+			case <-layerStopped:
+			case <-timeout:
+				timeoutErr = a.layerError(i, PhaseStop, StopTimeoutError{Tasks: lr.runningTasks()})
+			case <-forceCtx.Done():
+				return
+			}
+
+			layerErr := errors.Join(timeoutErr, lr.stopError())
+			a.observer.LayerStopFinished(a.cfg.layerInfo(i), time.Since(layerStart), layerErr)
+
+			mu.Lock()
+			defer mu.Unlock()
+			finished[i] = true
+			if timeoutErr != nil {
+				errs = append(errs, timeoutErr)
+			}
+			if err := lr.stopError(); err != nil {
+				errs = append(errs, err)
+			}
+			close(stopped[i])
+		})
+	}
+
+	allStopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allStopped)
+	}()
+	select {
+	case <-allStopped:
+		return errs, false
+	case sig := <-force:
+		cancelForce()
+		<-allStopped
+		for i, lr := range layers {
+			if !finished[i] {
+				lr.cancel(cause)
+			}
+		}
+		for i, lr := range slices.Backward(layers) {
+			if finished[i] {
+				continue
+			}
+			if err := lr.stopError(); err != nil {
+				errs = append(errs, err)
+			}
+			if tasks := lr.runningTasks(); len(tasks) > 0 {
+				errs = append(errs, a.layerError(i, PhaseStop, ForcedStopError{Signal: sig, Tasks: tasks}))
+			}
+		}
+		return errs, true
+	}
+}
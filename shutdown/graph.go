@@ -0,0 +1,156 @@
+package shutdown
+
+import (
+	"fmt"
+	"github.com/oomamontov/grace/shutdown/task"
+	"strings"
+)
+
+// DependsOn makes the layer depend on layers or nodes with provided names
+// instead of the previously registered layer.
+// The layer is initialized after all its dependencies are initialized and stopped before any of them is stopped.
+func DependsOn(names ...string) func(*Layer) {
+	return func(layer *Layer) {
+		layer.dependsOn.Set(names)
+	}
+}
+
+// RegisterNode registers runner as a node of dependency graph.
+// Unlike layers registered with Register, the node depends only on layers or nodes provided with DependsOn option,
+// so independent nodes are initialized and stopped concurrently.
+// Node is a named layer of a single task, so any other layer option might be provided as well.
+// The task is named after the node unless runner is a task.Task with its own name.
+func (c Config) RegisterNode(name string, runner task.Runner, opts ...func(*Layer)) Config {
+	t, ok := runner.(task.Task)
+	if !ok {
+		t = task.New(runner)
+	}
+	if !t.Name().IsSet() {
+		t = t.With(task.WithName(name))
+	}
+	runner = t
+	opts = append([]func(*Layer){WithLayerName(name), DependsOn()}, opts...)
+	return c.RegisterLayer(NewLayer([]task.Runner{runner}, opts...))
+}
+
+// UnknownDependencyError reports dependency on a layer that is not registered.
+type UnknownDependencyError struct {
+	Layer      string
+	Dependency string
+}
+
+func (e UnknownDependencyError) Error() string {
+	return fmt.Sprintf("layer %q depends on unknown layer %q", e.Layer, e.Dependency)
+}
+
+// AmbiguousDependencyError reports dependency on a name shared by several layers.
+type AmbiguousDependencyError struct {
+	Layer      string
+	Dependency string
+}
+
+func (e AmbiguousDependencyError) Error() string {
+	return fmt.Sprintf("layer %q depends on ambiguous name %q shared by several layers", e.Layer, e.Dependency)
+}
+
+// DependencyCycleError reports layers forming a dependency cycle.
+// The first layer is repeated at the end.
+type DependencyCycleError struct {
+	Layers []string
+}
+
+func (e DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Layers, " -> "))
+}
+
+// graph holds dependencies between registered layers by their indexes.
+type graph struct {
+	deps       [][]int // layers each layer depends on
+	dependents [][]int // layers depending on each layer
+}
+
+// resolveGraph resolves dependencies of registered layers and checks them for cycles.
+// Layers without DependsOn option depend on the previously registered layer.
+func (c Config) resolveGraph() (graph, error) {
+	byName := make(map[string][]int)
+	for i, layer := range c.layers {
+		if name, ok := layer.name.Get(); ok {
+			byName[name] = append(byName[name], i)
+		}
+	}
+
+	g := graph{
+		deps:       make([][]int, len(c.layers)),
+		dependents: make([][]int, len(c.layers)),
+	}
+	for i, layer := range c.layers {
+		names, ok := layer.dependsOn.Get()
+		if !ok && i > 0 {
+			g.deps[i] = []int{i - 1}
+		}
+		for _, name := range names {
+			switch idx := byName[name]; len(idx) {
+			case 0:
+				return graph{}, UnknownDependencyError{Layer: c.layerInfo(i).String(), Dependency: name}
+			case 1:
+				g.deps[i] = append(g.deps[i], idx[0])
+			default:
+				return graph{}, AmbiguousDependencyError{Layer: c.layerInfo(i).String(), Dependency: name}
+			}
+		}
+		for _, dep := range g.deps[i] {
+			g.dependents[dep] = append(g.dependents[dep], i)
+		}
+	}
+
+	if cycle := g.findCycle(); cycle != nil {
+		names := make([]string, 0, len(cycle))
+		for _, i := range cycle {
+			names = append(names, c.layerInfo(i).String())
+		}
+		return graph{}, DependencyCycleError{Layers: names}
+	}
+	return g, nil
+}
+
+// findCycle returns layers forming a dependency cycle with the first layer repeated at the end,
+// or nil if there are no cycles.
+func (g graph) findCycle() []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.deps))
+	var path []int
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		state[i] = visiting
+		path = append(path, i)
+		for _, dep := range g.deps[i] {
+			switch state[dep] {
+			case visiting:
+				for j, k := range path {
+					if k == dep {
+						return append(path[j:], dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range g.deps {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
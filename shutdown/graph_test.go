@@ -0,0 +1,108 @@
+package shutdown
+
+import (
+	"context"
+	"github.com/oomamontov/grace/shutdown/task"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegisterNode(t *testing.T) {
+	t.Parallel()
+	var events recorder
+	// kv and relational storages are independent: Init of each of them waits for Init of the other one to start,
+	// which deadlocks unless they are initialized concurrently
+	kvStarted, rStarted := make(chan struct{}), make(chan struct{})
+	node := func(name string, started chan struct{}, wait <-chan struct{}) initRunner {
+		return initRunner{
+			init: func(ctx context.Context) error {
+				if started != nil {
+					close(started)
+				}
+				if wait != nil {
+					<-wait
+				}
+				events.record("init " + name)
+				return nil
+			},
+			run: func(ctx context.Context) error {
+				<-ctx.Done()
+				events.record("stop " + name)
+				return nil
+			},
+		}
+	}
+
+	app := New().WithDefaultValues().
+		RegisterNode("service", node("service", nil, nil), DependsOn("kv", "cache")).
+		RegisterNode("cache", node("cache", nil, nil), DependsOn("relational")).
+		RegisterNode("kv", node("kv", kvStarted, rStarted)).
+		RegisterNode("relational", node("relational", rStarted, kvStarted)).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return app.State() == StateRunning
+	}, time.Second, time.Millisecond)
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+
+	order := events.get()
+	index := func(s string) int {
+		for i, v := range order {
+			if v == s {
+				return i
+			}
+		}
+		t.Fatalf("%q not found in %v", s, order)
+		return -1
+	}
+	require.Less(t, index("init relational"), index("init cache"))
+	require.Less(t, index("init cache"), index("init service"))
+	require.Less(t, index("init kv"), index("init service"))
+	require.Less(t, index("stop service"), index("stop cache"))
+	require.Less(t, index("stop service"), index("stop kv"))
+	require.Less(t, index("stop cache"), index("stop relational"))
+}
+
+func TestDependencyErrors(t *testing.T) {
+	t.Parallel()
+	runner := funcRunner(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	err := New().WithDefaultValues().
+		RegisterNode("a", runner, DependsOn("c")).
+		RegisterNode("b", runner, DependsOn("a")).
+		RegisterNode("c", runner, DependsOn("b")).
+		Run(t.Context())
+	var cycleErr DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
+	require.Equal(t, []string{"a", "c", "b", "a"}, cycleErr.Layers)
+
+	err = New().WithDefaultValues().
+		RegisterNode("a", runner, DependsOn("b")).
+		Run(t.Context())
+	require.ErrorIs(t, err, UnknownDependencyError{Layer: "a", Dependency: "b"})
+}
+
+func TestRegisterNodeTaskName(t *testing.T) {
+	t.Parallel()
+	var started sync.WaitGroup
+	started.Add(2)
+	failing := funcRunner(func(_ context.Context) error {
+		started.Done()
+		started.Wait()
+		return errTest
+	})
+	err := New().WithDefaultValues().
+		RegisterNode("cache", task.New(failing, task.WithStopPriority(1))).
+		RegisterNode("kv", task.New(failing, task.WithName("redis"))).
+		Run(t.Context())
+	var names []string
+	for taskErr := range TaskErrors(err) {
+		names = append(names, taskErr.Task)
+	}
+	require.ElementsMatch(t, []string{"cache", "redis"}, names)
+}
@@ -0,0 +1,56 @@
+package shutdown
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StartupHandler returns handler of the startup probe, e.g. /startupz.
+// It responds with 200 once all layers are initialized and with 503 before that.
+func (a *App) StartupHandler() http.Handler {
+	return probeHandler(func() error {
+		if !a.started.Load() {
+			return fmt.Errorf("not started: %s", a.State())
+		}
+		return nil
+	})
+}
+
+// ReadinessHandler returns handler of the readiness probe, e.g. /readyz.
+// It responds with 200 while the application is running and with 503 otherwise.
+// Readiness fails as soon as shutdown is requested, before pre-stop delay and stopping of any layer, see App.Ready.
+func (a *App) ReadinessHandler() http.Handler {
+	return probeHandler(func() error {
+		if !a.Ready() {
+			return fmt.Errorf("not ready: %s", a.State())
+		}
+		return nil
+	})
+}
+
+// LivenessHandler returns handler of the liveness probe, e.g. /livez.
+// It responds with 503 once the application is stopping because of a task failure and with 200 otherwise.
+func (a *App) LivenessHandler() http.Handler {
+	return probeHandler(func() error {
+		if cause := a.Cause(); errors.As(cause, new(TaskFailed)) {
+			return fmt.Errorf("not alive: %s", cause.Error())
+		}
+		return nil
+	})
+}
+
+// probeHandler returns handler responding with 503 and the error returned by check, or with 200 if it is nil.
+func probeHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintln(w, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,151 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(h http.Handler) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec.Code
+}
+
+func TestHealthHandlers(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	failure := make(chan struct{})
+	app := New().WithDefaultValues().
+		Register(initRunner{
+			init: func(_ context.Context) error {
+				<-release
+				return nil
+			},
+			run: func(ctx context.Context) error {
+				select {
+				case <-failure:
+					return errTest
+				case <-ctx.Done():
+					return nil
+				}
+			},
+		}).
+		Start(t.Context())
+
+	require.Equal(t, http.StatusServiceUnavailable, probe(app.StartupHandler()))
+	require.Equal(t, http.StatusServiceUnavailable, probe(app.ReadinessHandler()))
+	require.Equal(t, http.StatusOK, probe(app.LivenessHandler()))
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return app.State() == StateRunning
+	}, time.Second, time.Millisecond)
+	require.Equal(t, http.StatusOK, probe(app.StartupHandler()))
+	require.Equal(t, http.StatusOK, probe(app.ReadinessHandler()))
+	require.Equal(t, http.StatusOK, probe(app.LivenessHandler()))
+
+	close(failure)
+	require.ErrorIs(t, app.Wait(), errTest)
+	require.Equal(t, http.StatusOK, probe(app.StartupHandler()))
+	require.Equal(t, http.StatusServiceUnavailable, probe(app.ReadinessHandler()))
+	require.Equal(t, http.StatusServiceUnavailable, probe(app.LivenessHandler()))
+}
+
+type probingStopper struct {
+	probe   func() int
+	results chan int
+}
+
+func (s probingStopper) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s probingStopper) Stop(_ context.Context) error {
+	s.results <- s.probe()
+	return nil
+}
+
+func TestReadinessOnShutdown(t *testing.T) {
+	t.Parallel()
+	stopper := probingStopper{results: make(chan int, 1)}
+	var app *App
+	stopper.probe = func() int {
+		return probe(app.ReadinessHandler())
+	}
+	app = New().WithDefaultValues().
+		Register(stopper).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return app.State() == StateRunning
+	}, time.Second, time.Millisecond)
+
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+	require.Equal(t, http.StatusServiceUnavailable, <-stopper.results)
+	require.Equal(t, http.StatusOK, probe(app.LivenessHandler()))
+}
+
+type healthRunner struct {
+	err error
+}
+
+func (r healthRunner) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (r healthRunner) CheckHealth(_ context.Context) error {
+	return r.err
+}
+
+func TestHealthChecks(t *testing.T) {
+	t.Parallel()
+	app := New().WithDefaultValues().
+		WithHealthCheckInterval(time.Millisecond).
+		RegisterNode("relational", healthRunner{err: errTest}).
+		RegisterNode("kv", healthRunner{}).
+		RegisterNode("cache", healthRunner{}, DependsOn("relational")).
+		RegisterNode("service", healthRunner{}, DependsOn("cache", "kv")).
+		Start(t.Context())
+	require.Equal(t, HealthUnknown, app.Health().Status)
+	require.Equal(t, http.StatusServiceUnavailable, probe(app.HealthHandler()))
+
+	require.Eventually(t, func() bool {
+		return app.Health().Status != HealthUnknown
+	}, time.Second, time.Millisecond)
+	report := app.Health()
+	require.Equal(t, HealthUnhealthy, report.Status)
+	statuses := make(map[string]HealthStatus)
+	for _, th := range report.Tasks {
+		statuses[th.Task] = th.Status
+		switch th.Task {
+		case "relational":
+			require.Equal(t, `check health of task "relational": test error`, th.Error)
+		case "cache", "service":
+			require.Equal(t, []string{"relational"}, th.DegradedBy)
+		}
+	}
+	require.Equal(t, map[string]HealthStatus{
+		"relational": HealthUnhealthy,
+		"kv":         HealthHealthy,
+		"cache":      HealthDegraded,
+		"service":    HealthDegraded,
+	}, statuses)
+
+	rec := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var decoded HealthReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded))
+	require.Equal(t, HealthUnhealthy, decoded.Status)
+	require.Len(t, decoded.Tasks, 4)
+
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+}
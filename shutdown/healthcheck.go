@@ -0,0 +1,221 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HealthStatus is a status of a task in HealthReport.
+type HealthStatus string
+
+const (
+	HealthUnknown   HealthStatus = "unknown"   // task has not been checked yet
+	HealthHealthy   HealthStatus = "healthy"   // task and all layers it depends on are healthy
+	HealthDegraded  HealthStatus = "degraded"  // task is healthy, but some layer it depends on is not
+	HealthUnhealthy HealthStatus = "unhealthy" // task health check failed
+)
+
+// TaskHealth is a cached result of the task health check.
+type TaskHealth struct {
+	Layer      string        `json:"layer"`
+	Task       string        `json:"task"`
+	Status     HealthStatus  `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	DegradedBy []string      `json:"degraded_by,omitempty"` // unhealthy layers the task depends on, directly or not
+	CheckedAt  time.Time     `json:"checked_at,omitzero"`
+	Duration   time.Duration `json:"duration,omitempty"`
+}
+
+// HealthReport is a health of all tasks of the application.
+type HealthReport struct {
+	Status HealthStatus `json:"status"` // the worst status of tasks
+	Tasks  []TaskHealth `json:"tasks"`
+}
+
+// WithHealthCheckInterval sets interval of calling CheckHealth of tasks implementing task.HealthChecker
+// during Run stage. Results are cached and reported by App.Health and App.HealthHandler.
+// Default: 10s with WithDefaultValues; health is not checked otherwise.
+func (c Config) WithHealthCheckInterval(d time.Duration) Config {
+	c.healthCheckInterval.Set(d)
+	return c
+}
+
+// WithHealthCheckTimeout limits time of each CheckHealth call.
+// Default: 5s with WithDefaultValues, unlimited otherwise.
+func (c Config) WithHealthCheckTimeout(d time.Duration) Config {
+	c.healthCheckTimeout.Set(d)
+	return c
+}
+
+// healthCheck is a cached result of a single health check.
+type healthCheck struct {
+	checked   bool
+	err       error
+	checkedAt time.Time
+	duration  time.Duration
+}
+
+// healthChecks holds cached results of health checks indexed by layer and task, indexed as Layer.allTasks.
+type healthChecks struct {
+	mu      sync.RWMutex
+	results [][]healthCheck
+}
+
+// Health returns the last results of health checks with failures propagated upward:
+// tasks of layers depending on a layer with unhealthy tasks, directly or not, are reported as degraded.
+// Before the application is running all tasks are reported with HealthUnknown status.
+func (a *App) Health() HealthReport {
+	a.health.mu.RLock()
+	defer a.health.mu.RUnlock()
+
+	checked := a.health.results != nil // graph is resolved before the first check
+	unhealthy := make([]bool, len(a.cfg.layers))
+	if checked {
+		for i, results := range a.health.results {
+			unhealthy[i] = slices.ContainsFunc(results, func(c healthCheck) bool {
+				return c.err != nil
+			})
+		}
+	}
+
+	report := HealthReport{Status: HealthHealthy}
+	for i, layer := range a.cfg.layers {
+		var degradedBy []string
+		if checked {
+			degradedBy = a.unhealthyDeps(i, unhealthy)
+		}
+		for j := range layer.allTasks() {
+			var check healthCheck
+			if checked {
+				check = a.health.results[i][j]
+			}
+			th := TaskHealth{
+				Layer:     a.cfg.layerInfo(i).String(),
+				Task:      layer.taskName(j),
+				Status:    HealthUnknown,
+				CheckedAt: check.checkedAt,
+				Duration:  check.duration,
+			}
+			switch {
+			case !check.checked:
+			case check.err != nil:
+				th.Status = HealthUnhealthy
+				th.Error = check.err.Error()
+			case len(degradedBy) > 0:
+				th.Status = HealthDegraded
+				th.DegradedBy = degradedBy
+			default:
+				th.Status = HealthHealthy
+			}
+			report.Status = worseHealth(report.Status, th.Status)
+			report.Tasks = append(report.Tasks, th)
+		}
+	}
+	return report
+}
+
+// unhealthyDeps returns names of unhealthy layers the i-th layer depends on, directly or not.
+func (a *App) unhealthyDeps(i int, unhealthy []bool) []string {
+	var res []string
+	visited := make([]bool, len(a.cfg.layers))
+	var visit func(i int)
+	visit = func(i int) {
+		for _, dep := range a.graph.deps[i] {
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if unhealthy[dep] {
+				res = append(res, a.cfg.layerInfo(dep).String())
+			}
+			visit(dep)
+		}
+	}
+	visit(i)
+	return res
+}
+
+// worseHealth returns the worse of statuses.
+func worseHealth(s1, s2 HealthStatus) HealthStatus {
+	rank := map[HealthStatus]int{
+		HealthHealthy:   0,
+		HealthUnknown:   1,
+		HealthDegraded:  2,
+		HealthUnhealthy: 3,
+	}
+	if rank[s2] > rank[s1] {
+		return s2
+	}
+	return s1
+}
+
+// HealthHandler returns handler responding with HealthReport as JSON.
+// It responds with 200 if all tasks are healthy and with 503 otherwise.
+func (a *App) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		report := a.Health()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != HealthHealthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// watchHealth calls CheckHealth of all tasks every health check interval until the application is stopping
+// or done is closed. Tasks are checked in parallel, each check is limited by health check timeout.
+func (a *App) watchHealth(ctx context.Context, done <-chan struct{}) {
+	interval, ok := a.cfg.healthCheckInterval.Get()
+	if !ok || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for a.State() == StateRunning {
+		a.checkHealth(ctx)
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// checkHealth calls CheckHealth of all tasks in parallel and caches results.
+func (a *App) checkHealth(ctx context.Context) {
+	results := make([][]healthCheck, len(a.cfg.layers))
+	var wg sync.WaitGroup
+	for i, layer := range a.cfg.layers {
+		results[i] = make([]healthCheck, len(layer.tasks)+len(layer.backgroundTasks))
+		for j, t := range layer.allTasks() {
+			wg.Go(func() {
+				checkCtx := ctx
+				if d, ok := a.cfg.healthCheckTimeout.Get(); ok {
+					var cancel context.CancelFunc
+					checkCtx, cancel = context.WithTimeout(ctx, d)
+					defer cancel()
+				}
+				start := time.Now()
+				err := t.CheckHealth(checkCtx)
+				results[i][j] = healthCheck{
+					checked:   true,
+					err:       err,
+					checkedAt: start,
+					duration:  time.Since(start),
+				}
+			})
+		}
+	}
+	wg.Wait()
+
+	a.health.mu.Lock()
+	defer a.health.mu.Unlock()
+	a.health.results = results
+}
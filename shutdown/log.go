@@ -0,0 +1,238 @@
+package shutdown
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LogLevels configures levels of lifecycle records logged by the logger provided with Config.WithLogger.
+// Nil fields are replaced with defaults.
+type LogLevels struct {
+	Init     slog.Leveler // successful initialization of layers and tasks; default: slog.LevelInfo
+	Run      slog.Leveler // start of tasks Run, readiness of tasks and of the application; default: slog.LevelInfo
+	Restart  slog.Leveler // restart of tasks by their restart policy; default: slog.LevelWarn
+	Shutdown slog.Leveler // shutdown request; default: slog.LevelInfo
+	Stop     slog.Leveler // successful stop of layers and tasks; default: slog.LevelInfo
+	Failure  slog.Leveler // failed initialization or stop of layers and tasks; default: slog.LevelError
+}
+
+// WithLogger makes the application log its lifecycle: initialization, start of Run, readiness, restarts
+// and stop of every layer and task, readiness of the application and the shutdown request. Records have layer, task, phase, duration and error attributes where applicable.
+// Logging is done by an Observer working alongside the one provided with WithObserver.
+func (c Config) WithLogger(logger *slog.Logger) Config {
+	c.logger.Set(logger)
+	return c
+}
+
+// WithLogLevels sets levels of lifecycle records, see WithLogger.
+func (c Config) WithLogLevels(levels LogLevels) Config {
+	c.logLevels.Set(levels)
+	return c
+}
+
+// newObserver returns observer combining the configured one and the lifecycle logger.
+func (c Config) newObserver() Observer {
+	observer := c.observer.Or(NopObserver{})
+	logger, ok := c.logger.Get()
+	if !ok {
+		return observer
+	}
+	levels := c.logLevels.GetOrDefault()
+	return multiObserver{
+		logObserver{
+			log:      logger,
+			init:     leveler(levels.Init, slog.LevelInfo),
+			run:      leveler(levels.Run, slog.LevelInfo),
+			restart:  leveler(levels.Restart, slog.LevelWarn),
+			shutdown: leveler(levels.Shutdown, slog.LevelInfo),
+			stop:     leveler(levels.Stop, slog.LevelInfo),
+			failure:  leveler(levels.Failure, slog.LevelError),
+		},
+		observer,
+	}
+}
+
+func leveler(l slog.Leveler, def slog.Level) slog.Leveler {
+	if l == nil {
+		return def
+	}
+	return l
+}
+
+// logObserver is an Observer logging lifecycle events.
+type logObserver struct {
+	log                                         *slog.Logger
+	init, run, restart, shutdown, stop, failure slog.Leveler
+}
+
+// layerAttr returns layer attribute: layer name or its index if the layer is unnamed.
+func layerAttr(layer LayerInfo) slog.Attr {
+	return slog.String("layer", layer.String())
+}
+
+func (o logObserver) logTask(msg string, phase Phase, level slog.Leveler, task TaskInfo, d time.Duration, err error) {
+	attrs := []slog.Attr{
+		layerAttr(task.Layer),
+		slog.String("task", task.Name),
+		slog.String("phase", string(phase)),
+		slog.Duration("duration", d),
+	}
+	if err != nil {
+		level = o.failure
+		attrs = append(attrs, slog.Any("error", errorValue(err)))
+	}
+	o.log.LogAttrs(context.Background(), level.Level(), msg, attrs...)
+}
+
+func (o logObserver) logLayer(msg string, phase Phase, level slog.Leveler, layer LayerInfo, d time.Duration, err error) {
+	attrs := []slog.Attr{
+		layerAttr(layer),
+		slog.String("phase", string(phase)),
+		slog.Duration("duration", d),
+	}
+	if err != nil {
+		level = o.failure
+		attrs = append(attrs, slog.Any("error", errorValue(err)))
+	}
+	o.log.LogAttrs(context.Background(), level.Level(), msg, attrs...)
+}
+
+func (o logObserver) LayerInitStarted(layer LayerInfo) {
+	o.log.LogAttrs(context.Background(), o.init.Level(), "Layer init started",
+		layerAttr(layer),
+		slog.String("phase", string(PhaseInit)),
+	)
+}
+
+func (o logObserver) TaskInitFinished(task TaskInfo, d time.Duration, err error) {
+	o.logTask("Task init finished", PhaseInit, o.init, task, d, err)
+}
+
+func (o logObserver) LayerInitFinished(layer LayerInfo, d time.Duration, err error) {
+	o.logLayer("Layer init finished", PhaseInit, o.init, layer, d, err)
+}
+
+func (o logObserver) TaskRunStarted(task TaskInfo) {
+	o.log.LogAttrs(context.Background(), o.run.Level(), "Task run started",
+		layerAttr(task.Layer),
+		slog.String("task", task.Name),
+		slog.String("phase", string(PhaseRun)),
+	)
+}
+
+func (o logObserver) TaskReady(task TaskInfo) {
+	o.log.LogAttrs(context.Background(), o.run.Level(), "Task ready",
+		layerAttr(task.Layer),
+		slog.String("task", task.Name),
+		slog.String("phase", string(PhaseRun)),
+	)
+}
+
+func (o logObserver) TaskRestarted(task TaskInfo, restart int, err error) {
+	o.log.LogAttrs(context.Background(), o.restart.Level(), "Task restarted",
+		layerAttr(task.Layer),
+		slog.String("task", task.Name),
+		slog.String("phase", string(PhaseRun)),
+		slog.Int("restart", restart),
+		slog.Any("error", errorValue(err)),
+	)
+}
+
+func (o logObserver) AppReady(d time.Duration) {
+	o.log.LogAttrs(context.Background(), o.run.Level(), "Application ready",
+		slog.String("phase", string(PhaseRun)),
+		slog.Duration("duration", d),
+	)
+}
+
+func (o logObserver) ShutdownRequested(cause error) {
+	o.log.LogAttrs(context.Background(), o.shutdown.Level(), "Shutdown requested",
+		slog.String("phase", string(PhaseShutdown)),
+		slog.Any("cause", errorValue(cause)),
+	)
+}
+
+func (o logObserver) LayerStopStarted(layer LayerInfo) {
+	o.log.LogAttrs(context.Background(), o.stop.Level(), "Layer stop started",
+		layerAttr(layer),
+		slog.String("phase", string(PhaseStop)),
+	)
+}
+
+func (o logObserver) TaskStopped(task TaskInfo, d time.Duration, err error) {
+	o.logTask("Task stopped", PhaseStop, o.stop, task, d, err)
+}
+
+func (o logObserver) LayerStopFinished(layer LayerInfo, d time.Duration, err error) {
+	o.logLayer("Layer stop finished", PhaseStop, o.stop, layer, d, err)
+}
+
+// multiObserver is an Observer passing events to each of observers in order.
+type multiObserver []Observer
+
+func (m multiObserver) LayerInitStarted(layer LayerInfo) {
+	for _, o := range m {
+		o.LayerInitStarted(layer)
+	}
+}
+
+func (m multiObserver) TaskInitFinished(task TaskInfo, d time.Duration, err error) {
+	for _, o := range m {
+		o.TaskInitFinished(task, d, err)
+	}
+}
+
+func (m multiObserver) LayerInitFinished(layer LayerInfo, d time.Duration, err error) {
+	for _, o := range m {
+		o.LayerInitFinished(layer, d, err)
+	}
+}
+
+func (m multiObserver) TaskRunStarted(task TaskInfo) {
+	for _, o := range m {
+		o.TaskRunStarted(task)
+	}
+}
+
+func (m multiObserver) TaskReady(task TaskInfo) {
+	for _, o := range m {
+		o.TaskReady(task)
+	}
+}
+
+func (m multiObserver) TaskRestarted(task TaskInfo, restart int, err error) {
+	for _, o := range m {
+		o.TaskRestarted(task, restart, err)
+	}
+}
+
+func (m multiObserver) AppReady(d time.Duration) {
+	for _, o := range m {
+		o.AppReady(d)
+	}
+}
+
+func (m multiObserver) ShutdownRequested(cause error) {
+	for _, o := range m {
+		o.ShutdownRequested(cause)
+	}
+}
+
+func (m multiObserver) LayerStopStarted(layer LayerInfo) {
+	for _, o := range m {
+		o.LayerStopStarted(layer)
+	}
+}
+
+func (m multiObserver) TaskStopped(task TaskInfo, d time.Duration, err error) {
+	for _, o := range m {
+		o.TaskStopped(task, d, err)
+	}
+}
+
+func (m multiObserver) LayerStopFinished(layer LayerInfo, d time.Duration, err error) {
+	for _, o := range m {
+		o.LayerStopFinished(layer, d, err)
+	}
+}
@@ -0,0 +1,136 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/oomamontov/grace/shutdown/task"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	err := New().WithDefaultValues().
+		WithLogger(logger).
+		WithLogLevels(LogLevels{Init: slog.LevelDebug, Run: slog.LevelDebug}).
+		RegisterLayer(NewLayer(
+			[]task.Runner{task.New(failingRunner(), task.WithName("failing"))},
+			WithLayerName("api"),
+		)).
+		Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]any
+		require.NoError(t, dec.Decode(&record))
+		records = append(records, record)
+	}
+	require.Equal(t, []map[string]any{
+		{
+			"level": "INFO",
+			"msg":   "Shutdown requested",
+			"phase": "shutdown",
+			"cause": `task "failing" of layer "api" failed: run task "failing": test error`,
+		},
+		{
+			"level": "INFO",
+			"msg":   "Layer stop started",
+			"layer": "api",
+			"phase": "stop",
+		},
+		{
+			"level": "ERROR",
+			"msg":   "Task stopped",
+			"layer": "api",
+			"task":  "failing",
+			"phase": "stop",
+			"error": map[string]any{
+				"task":   "failing",
+				"action": "run",
+				"error":  "test error",
+			},
+		},
+		{
+			"level": "INFO",
+			"msg":   "Layer stop finished",
+			"layer": "api",
+			"phase": "stop",
+		},
+	}, records)
+}
+
+func TestLoggerPhases(t *testing.T) {
+	t.Parallel()
+	var buf syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	app := New().WithDefaultValues().
+		WithLogger(logger).
+		Register(task.New(funcRunner(func(ctx context.Context) error {
+			task.ReadyFuncFromContext(ctx)()
+			<-ctx.Done()
+			return nil
+		}), task.WithName("server"))).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), "Application ready")
+	}, time.Second, time.Millisecond)
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+
+	var messages []string
+	dec := json.NewDecoder(strings.NewReader(buf.String()))
+	for dec.More() {
+		var record struct {
+			Msg string `json:"msg"`
+		}
+		require.NoError(t, dec.Decode(&record))
+		messages = append(messages, record.Msg)
+	}
+	require.ElementsMatch(t, []string{
+		"Layer init started",
+		"Task init finished",
+		"Layer init finished",
+		"Task run started",
+		"Task ready",
+		"Application ready",
+		"Shutdown requested",
+		"Layer stop started",
+		"Task stopped",
+		"Layer stop finished",
+	}, messages)
+}
+
+// syncBuffer is a goroutine-safe bytes.Buffer.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,154 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oomamontov/grace/pkg/optional"
+	"log/slog"
+	"os"
+	"slices"
+)
+
+// Outcome is a result of the application run, see OutcomeOf.
+type Outcome int
+
+const (
+	OutcomeClean           Outcome = iota // stopped without errors or interrupted during initialization
+	OutcomeInitFailure                    // failed to start
+	OutcomeRunFailure                     // stopped because of a task failure or with stop errors
+	OutcomeShutdownTimeout                // some layers did not stop within stop timeouts
+	OutcomeForced                         // shutdown was forced by repeated interrupt signals
+	OutcomeDegraded                       // stopped with errors of optional tasks only, see task.WithCriticality
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeClean:
+		return "clean"
+	case OutcomeInitFailure:
+		return "init failure"
+	case OutcomeRunFailure:
+		return "run failure"
+	case OutcomeShutdownTimeout:
+		return "shutdown timeout"
+	case OutcomeForced:
+		return "forced"
+	case OutcomeDegraded:
+		return "degraded"
+	default:
+		return fmt.Sprintf("Outcome(%d)", int(o))
+	}
+}
+
+// OutcomeOf classifies error returned by Run. If several outcomes apply, the most severe one is returned:
+// forced stop, shutdown timeout, init failure, run failure and degraded run in this order.
+func OutcomeOf(err error) Outcome {
+	if err == nil {
+		return OutcomeClean
+	}
+	if errors.As(err, new(ForcedStopError)) {
+		return OutcomeForced
+	}
+	if errors.As(err, new(StopTimeoutError)) {
+		return OutcomeShutdownTimeout
+	}
+	var runErr RunError
+	if errors.As(err, &runErr) && runErr.Phase == PhaseInit {
+		// interruption without failed tasks is a regular stop
+		if errors.As(err, new(InitInterruptedError)) && !errors.As(err, new(LayerError)) {
+			return OutcomeClean
+		}
+		return OutcomeInitFailure
+	}
+	if optionalOnly(err) {
+		return OutcomeDegraded
+	}
+	return OutcomeRunFailure
+}
+
+// optionalOnly reports whether err consists of failures of optional tasks only.
+func optionalOnly(err error) bool {
+	switch e := err.(type) {
+	case OptionalTaskError:
+		return true
+	case interface{ Unwrap() []error }:
+		inner := e.Unwrap()
+		return len(inner) > 0 && !slices.ContainsFunc(inner, func(err error) bool {
+			return !optionalOnly(err)
+		})
+	case interface{ Unwrap() error }:
+		inner := e.Unwrap()
+		return inner != nil && optionalOnly(inner)
+	default:
+		return false
+	}
+}
+
+// MainOptions configures Main.
+type MainOptions struct {
+	logger    optional.Value[*slog.Logger] // default: slog.Default()
+	exitCodes map[Outcome]int
+	exit      func(code int) // replaced in tests
+}
+
+// WithMainLogger sets logger of the application outcome.
+func WithMainLogger(logger *slog.Logger) func(*MainOptions) {
+	return func(opts *MainOptions) {
+		opts.logger.Set(logger)
+	}
+}
+
+// WithExitCode sets process exit code for the outcome.
+// Default codes are 0 for clean stop and degraded run, 1 for init failure, 2 for run failure,
+// 3 for shutdown timeout and 130 for forced stop.
+func WithExitCode(outcome Outcome, code int) func(*MainOptions) {
+	return func(opts *MainOptions) {
+		opts.exitCodes[outcome] = code
+	}
+}
+
+// Main runs the application configured by cfg, logs its outcome and exits the process
+// with exit code of the outcome, see OutcomeOf and WithExitCode.
+// Main is meant to be the last call of the main function.
+func Main(cfg Config, opts ...func(*MainOptions)) {
+	options := MainOptions{
+		exitCodes: map[Outcome]int{
+			OutcomeClean:           0,
+			OutcomeInitFailure:     1,
+			OutcomeRunFailure:      2,
+			OutcomeShutdownTimeout: 3,
+			OutcomeForced:          130,
+			OutcomeDegraded:        0,
+		},
+		exit: os.Exit,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	log := options.logger.Or(slog.Default())
+
+	err := cfg.Run(context.Background())
+	outcome := OutcomeOf(err)
+	code := options.exitCodes[outcome]
+	switch outcome {
+	case OutcomeClean:
+		log.Info("Application stopped",
+			slog.String("outcome", outcome.String()),
+			slog.Int("exit_code", code),
+		)
+	case OutcomeDegraded:
+		log.Warn("Application stopped with failed optional tasks",
+			slog.String("outcome", outcome.String()),
+			slog.Int("exit_code", code),
+			slog.Any("error", err),
+		)
+	default:
+		log.Error("Application failed",
+			slog.String("outcome", outcome.String()),
+			slog.Int("exit_code", code),
+			slog.Any("error", err),
+		)
+	}
+	options.exit(code)
+}
@@ -0,0 +1,90 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"syscall"
+	"testing"
+)
+
+func TestOutcomeOf(t *testing.T) {
+	t.Parallel()
+	layerErr := func(phase Phase, inner error) LayerError {
+		return LayerError{Phase: phase, Inner: inner}
+	}
+	for name, tc := range map[string]struct {
+		err     error
+		outcome Outcome
+	}{
+		"nil":         {nil, OutcomeClean},
+		"interrupted": {RunError{Phase: PhaseInit, Inner: InitInterruptedError{Cause: Requested{}}}, OutcomeClean},
+		"init": {
+			RunError{Phase: PhaseInit, Inner: layerErr(PhaseInit, errTest)},
+			OutcomeInitFailure,
+		},
+		"run": {
+			RunError{Phase: PhaseRun, Inner: layerErr(PhaseRun, errTest)},
+			OutcomeRunFailure,
+		},
+		"timeout": {
+			RunError{Phase: PhaseRun, Inner: layerErr(PhaseStop, StopTimeoutError{})},
+			OutcomeShutdownTimeout,
+		},
+		"degraded": {
+			RunError{Phase: PhaseRun, Inner: errors.Join(
+				layerErr(PhaseInit, OptionalTaskError{Failure: 1, Inner: errTest}),
+				layerErr(PhaseRun, OptionalTaskError{Failure: 2, Inner: errTest}),
+			)},
+			OutcomeDegraded,
+		},
+		"degraded and failed": {
+			RunError{Phase: PhaseRun, Inner: errors.Join(
+				layerErr(PhaseRun, OptionalTaskError{Failure: 1, Inner: errTest}),
+				layerErr(PhaseRun, errTest),
+			)},
+			OutcomeRunFailure,
+		},
+		"forced": {
+			RunError{Phase: PhaseRun, Inner: layerErr(PhaseStop, ForcedStopError{Signal: syscall.SIGTERM})},
+			OutcomeForced,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.outcome, OutcomeOf(tc.err))
+		})
+	}
+}
+
+func TestMainExitCodes(t *testing.T) {
+	t.Parallel()
+	run := func(cfg Config, opts ...func(*MainOptions)) (int, string) {
+		var buf bytes.Buffer
+		code := -1
+		opts = append(opts,
+			WithMainLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+			func(opts *MainOptions) {
+				opts.exit = func(c int) {
+					code = c
+				}
+			},
+		)
+		Main(cfg, opts...)
+		return code, buf.String()
+	}
+
+	code, log := run(New().WithDefaultValues().Register(failingRunner()))
+	require.Equal(t, 2, code)
+	require.Contains(t, log, "outcome=\"run failure\"")
+
+	code, _ = run(
+		New().WithDefaultValues().Register(initRunner{init: func(_ context.Context) error {
+			return errTest
+		}}),
+		WithExitCode(OutcomeInitFailure, 10),
+	)
+	require.Equal(t, 10, code)
+}
@@ -0,0 +1,100 @@
+package shutdown
+
+import (
+	"fmt"
+	"github.com/oomamontov/grace/pkg/optional"
+	"time"
+)
+
+// LayerInfo identifies a layer reported to Observer.
+type LayerInfo struct {
+	Index int // index of the layer in order of registration
+	Name  optional.Value[string]
+}
+
+// String returns name of the layer or its index if the layer is unnamed.
+func (l LayerInfo) String() string {
+	return l.Name.Or(fmt.Sprintf("#%d", l.Index))
+}
+
+// TaskInfo identifies a task reported to Observer.
+type TaskInfo struct {
+	Layer      LayerInfo
+	Name       string // unnamed tasks are reported by their runner type name and index within the layer
+	Background bool
+}
+
+// Observer receives lifecycle events of the application, e.g. to log them or to collect metrics.
+// Methods are called synchronously from multiple goroutines, so they should be goroutine-safe and return quickly.
+// Embed NopObserver to implement only some of the methods.
+type Observer interface {
+	// LayerInitStarted is called before Init of the layer tasks.
+	LayerInitStarted(layer LayerInfo)
+	// TaskInitFinished is called after Init of the task returns.
+	// It is not called for tasks abandoned on startup timeout.
+	TaskInitFinished(task TaskInfo, d time.Duration, err error)
+	// LayerInitFinished is called after Init of all the layer tasks returns or the layer is abandoned
+	// on startup timeout, with the layer error if any of its tasks failed.
+	LayerInitFinished(layer LayerInfo, d time.Duration, err error)
+	// TaskRunStarted is called before Run of the task is called.
+	// For optional tasks it is called before each retry as well, see task.WithCriticality.
+	TaskRunStarted(task TaskInfo)
+	// TaskReady is called once the task signals readiness from Run, see task.ReadyFuncFromContext.
+	TaskReady(task TaskInfo)
+	// TaskRestarted is called on each restart of the task by its restart policy, see task.WithRestartPolicy,
+	// with the number of the restart and the error causing it.
+	TaskRestarted(task TaskInfo, restart int, err error)
+	// AppReady is called once all layers are ready and the application is running,
+	// with time elapsed since the application start.
+	AppReady(d time.Duration)
+	// ShutdownRequested is called once with the cause of shutdown: SignalReceived, Requested or TaskFailed.
+	ShutdownRequested(cause error)
+	// LayerStopStarted is called before the first task of the layer is stopped.
+	LayerStopStarted(layer LayerInfo)
+	// TaskStopped is called after the task returns on shutdown with time elapsed since its stop started
+	// and both Stop and Run errors. Tasks returned before their stop started are reported with zero duration.
+	// It is not called for tasks abandoned on stop timeout or force stop.
+	TaskStopped(task TaskInfo, d time.Duration, err error)
+	// LayerStopFinished is called after all tasks of the layer return or the layer is abandoned on stop timeout,
+	// with the layer stop errors. It is not called for layers abandoned on force stop.
+	LayerStopFinished(layer LayerInfo, d time.Duration, err error)
+}
+
+// NopObserver is an Observer ignoring all events.
+type NopObserver struct{}
+
+func (NopObserver) LayerInitStarted(LayerInfo)                        {}
+func (NopObserver) TaskInitFinished(TaskInfo, time.Duration, error)   {}
+func (NopObserver) LayerInitFinished(LayerInfo, time.Duration, error) {}
+func (NopObserver) TaskRunStarted(TaskInfo)                           {}
+func (NopObserver) TaskReady(TaskInfo)                                {}
+func (NopObserver) TaskRestarted(TaskInfo, int, error)                {}
+func (NopObserver) AppReady(time.Duration)                            {}
+func (NopObserver) ShutdownRequested(error)                           {}
+func (NopObserver) LayerStopStarted(LayerInfo)                        {}
+func (NopObserver) TaskStopped(TaskInfo, time.Duration, error)        {}
+func (NopObserver) LayerStopFinished(LayerInfo, time.Duration, error) {}
+
+// WithObserver sets observer of the application lifecycle events.
+func (c Config) WithObserver(o Observer) Config {
+	c.observer.Set(o)
+	return c
+}
+
+// layerInfo returns info of the i-th registered layer.
+func (c Config) layerInfo(i int) LayerInfo {
+	return LayerInfo{
+		Index: i,
+		Name:  c.layers[i].name,
+	}
+}
+
+// taskInfo returns info of the j-th task of the i-th registered layer, indexed as Layer.allTasks.
+func (c Config) taskInfo(i, j int) TaskInfo {
+	layer := c.layers[i]
+	return TaskInfo{
+		Layer:      c.layerInfo(i),
+		Name:       layer.taskName(j),
+		Background: j >= len(layer.tasks),
+	}
+}
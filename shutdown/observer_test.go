@@ -0,0 +1,179 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"github.com/oomamontov/grace/shutdown/task"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) LayerInitStarted(layer LayerInfo) {
+	o.record("init layer %d", layer.Index)
+}
+
+func (o *recordingObserver) TaskInitFinished(task TaskInfo, _ time.Duration, err error) {
+	o.record("init task %s: %v", task.Name, err)
+}
+
+func (o *recordingObserver) LayerInitFinished(layer LayerInfo, _ time.Duration, err error) {
+	o.record("init layer %d done: %v", layer.Index, err)
+}
+
+func (o *recordingObserver) TaskRunStarted(task TaskInfo) {
+	o.record("run task %s", task.Name)
+}
+
+func (o *recordingObserver) TaskReady(task TaskInfo) {
+	o.record("ready task %s", task.Name)
+}
+
+func (o *recordingObserver) TaskRestarted(task TaskInfo, restart int, err error) {
+	o.record("restart task %s #%d: %v", task.Name, restart, err)
+}
+
+func (o *recordingObserver) AppReady(_ time.Duration) {
+	o.record("ready")
+}
+
+func (o *recordingObserver) ShutdownRequested(cause error) {
+	o.record("shutdown: %v", cause)
+}
+
+func (o *recordingObserver) LayerStopStarted(layer LayerInfo) {
+	o.record("stop layer %d", layer.Index)
+}
+
+func (o *recordingObserver) TaskStopped(task TaskInfo, _ time.Duration, err error) {
+	o.record("stop task %s: %v", task.Name, err)
+}
+
+func (o *recordingObserver) LayerStopFinished(layer LayerInfo, _ time.Duration, err error) {
+	o.record("stop layer %d done: %v", layer.Index, err)
+}
+
+func (o *recordingObserver) get() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.events)
+}
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+	runner := func(name string) task.Task {
+		return task.New(initRunner{
+			init: func(_ context.Context) error {
+				return nil
+			},
+			run: func(ctx context.Context) error {
+				task.ReadyFuncFromContext(ctx)()
+				<-ctx.Done()
+				return nil
+			},
+		}, task.WithName(name))
+	}
+
+	observer := &recordingObserver{}
+	app := New().WithDefaultValues().
+		WithObserver(observer).
+		Register(runner("storage")).
+		Register(runner("server")).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return slices.Contains(observer.get(), "ready")
+	}, time.Second, time.Millisecond)
+
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+	// Run of tasks of different layers is started concurrently, so run events are checked separately
+	events := observer.get()
+	running := slices.DeleteFunc(slices.Clone(events), func(e string) bool {
+		return !strings.HasPrefix(e, "run ") && !strings.HasPrefix(e, "ready ")
+	})
+	require.ElementsMatch(t, []string{
+		"run task storage",
+		"ready task storage",
+		"run task server",
+		"ready task server",
+	}, running)
+	require.Less(t, slices.Index(events, "run task storage"), slices.Index(events, "ready task storage"))
+	require.Equal(t, []string{
+		"init layer 0",
+		"init task storage: <nil>",
+		"init layer 0 done: <nil>",
+		"init layer 1",
+		"init task server: <nil>",
+		"init layer 1 done: <nil>",
+		"ready",
+		"shutdown: shutdown requested: test",
+		"stop layer 1",
+		"stop task server: <nil>",
+		"stop layer 1 done: <nil>",
+		"stop layer 0",
+		"stop task storage: <nil>",
+		"stop layer 0 done: <nil>",
+	}, slices.DeleteFunc(events, func(e string) bool {
+		return slices.Contains(running, e)
+	}))
+}
+
+func TestObserverRestarts(t *testing.T) {
+	t.Parallel()
+	var runs atomic.Int32
+	observer := &recordingObserver{}
+	app := New().WithDefaultValues().
+		WithObserver(observer).
+		WithFallibleBackgroundTasks(true).
+		RegisterLayer(NewLayer(nil, WithBackgroundTasks(task.New(funcRunner(func(ctx context.Context) error {
+			if runs.Add(1) <= 2 {
+				return errTest
+			}
+			<-ctx.Done()
+			return nil
+		}), task.WithName("cleaner"), task.WithRestartPolicy(task.RestartPolicy{
+			Backoff: task.Backoff{InitialBackoff: time.Millisecond},
+		}))))).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return runs.Load() == 3
+	}, time.Second, time.Millisecond)
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+
+	var restarts []string
+	for _, e := range observer.get() {
+		if strings.HasPrefix(e, "restart ") {
+			restarts = append(restarts, e)
+		}
+	}
+	require.Equal(t, []string{
+		"restart task cleaner #1: test error",
+		"restart task cleaner #2: test error",
+	}, restarts)
+}
+
+func TestNopObserver(t *testing.T) {
+	t.Parallel()
+	var observer struct{ NopObserver }
+	err := New().WithDefaultValues().
+		WithObserver(observer).
+		Register(failingRunner()).
+		Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+}
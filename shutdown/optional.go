@@ -0,0 +1,190 @@
+package shutdown
+
+import (
+	"cmp"
+	"context"
+	"github.com/oomamontov/grace/shutdown/task"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DegradedTask describes an optional task which has failed and is being retried, see task.WithCriticality.
+type DegradedTask struct {
+	Layer string
+	Task  string
+	Phase Phase     // phase of the last failure: PhaseInit or PhaseRun
+	Err   error     // the last failure
+	Since time.Time // time of the first failure since the task was last started
+}
+
+// WithOptionalTaskBackoff sets backoff between retries of failed optional tasks, see task.WithCriticality.
+func (c Config) WithOptionalTaskBackoff(b task.Backoff) Config {
+	c.optionalTaskBackoff.Set(b)
+	return c
+}
+
+// taskKey identifies a task by indexes of its layer and of the task within the layer, indexed as Layer.allTasks.
+type taskKey struct {
+	layer, task int
+}
+
+func (k taskKey) compare(other taskKey) int {
+	if c := cmp.Compare(k.layer, other.layer); c != 0 {
+		return c
+	}
+	return cmp.Compare(k.task, other.task)
+}
+
+// failures holds the number of failures of an optional task and errors of the first and the last of them,
+// so memory use is bounded even if the task keeps failing.
+type failures struct {
+	count       int
+	first, last error
+}
+
+// degradation holds failures of optional tasks.
+type degradation struct {
+	mu       sync.Mutex
+	tasks    map[taskKey]DegradedTask // tasks currently degraded
+	failures map[taskKey]*failures    // tasks failed at least once
+}
+
+// Degraded returns optional tasks which have failed and have not been started again yet,
+// in order of layers and tasks registration.
+func (a *App) Degraded() []DegradedTask {
+	a.degradation.mu.Lock()
+	defer a.degradation.mu.Unlock()
+	keys := slices.SortedFunc(maps.Keys(a.degradation.tasks), taskKey.compare)
+	res := make([]DegradedTask, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, a.degradation.tasks[k])
+	}
+	return res
+}
+
+// degrade records failure of the i-th optional task of the idx-th layer occurred in phase.
+func (a *App) degrade(idx, i int, phase Phase, err error) {
+	layer := a.cfg.layers[idx]
+	taskErr := layer.taskError(i, err)
+	key := taskKey{layer: idx, task: i}
+
+	a.degradation.mu.Lock()
+	defer a.degradation.mu.Unlock()
+	if a.degradation.tasks == nil {
+		a.degradation.tasks = make(map[taskKey]DegradedTask)
+		a.degradation.failures = make(map[taskKey]*failures)
+	}
+	since := time.Now()
+	if prev, ok := a.degradation.tasks[key]; ok {
+		since = prev.Since
+	}
+	a.degradation.tasks[key] = DegradedTask{
+		Layer: a.cfg.layerInfo(idx).String(),
+		Task:  taskErr.Task,
+		Phase: phase,
+		Err:   err,
+		Since: since,
+	}
+
+	f, ok := a.degradation.failures[key]
+	if !ok {
+		f = &failures{}
+		a.degradation.failures[key] = f
+	}
+	f.count++
+	layerErr := a.layerError(idx, phase, OptionalTaskError{Failure: f.count, Inner: taskErr})
+	if f.count == 1 {
+		f.first = layerErr
+	} else {
+		f.last = layerErr
+	}
+}
+
+// restore removes the i-th task of the idx-th layer from degraded tasks.
+func (a *App) restore(idx, i int) {
+	a.degradation.mu.Lock()
+	defer a.degradation.mu.Unlock()
+	delete(a.degradation.tasks, taskKey{layer: idx, task: i})
+}
+
+// degradedErrors returns the first and the last failures of each failed optional task.
+func (a *App) degradedErrors() []error {
+	a.degradation.mu.Lock()
+	defer a.degradation.mu.Unlock()
+	var errs []error
+	for _, k := range slices.SortedFunc(maps.Keys(a.degradation.failures), taskKey.compare) {
+		f := a.degradation.failures[k]
+		errs = append(errs, f.first)
+		if f.last != nil {
+			errs = append(errs, f.last)
+		}
+	}
+	return errs
+}
+
+// runOptional runs the i-th optional task of the idx-th layer, initializing it first if it is not initialized.
+// Failures of Init and Run degrade the application instead of stopping it,
+// and the task is retried with backoff until ctx is cancelled. Backoff is reset once Run starts.
+// A task which is not initialized has failed Init in initLayer, so it is retried after backoff too.
+func (a *App) runOptional(ctx context.Context, idx, i int, t task.Task, initialized bool) error {
+	backoff := a.cfg.optionalTaskBackoff.GetOrDefault()
+	attempt := 0
+	if !initialized {
+		attempt++
+		if !sleep(ctx, backoff.Delay(attempt)) {
+			return nil
+		}
+	}
+	for {
+		phase := PhaseRun
+		var err error
+		if !initialized {
+			phase = PhaseInit
+			err = t.Init(ctx)
+			initialized = err == nil
+			if initialized && ctx.Err() != nil {
+				// stopped before Run, so release resources acquired by Init
+				if err := t.Close(context.WithoutCancel(ctx)); err != nil {
+					a.degrade(idx, i, PhaseRollback, err)
+				}
+				return nil
+			}
+		}
+		if err == nil {
+			phase = PhaseRun
+			attempt = 0
+			a.restore(idx, i)
+			a.observer.TaskRunStarted(a.cfg.taskInfo(idx, i))
+			err = t.Run(ctx)
+			if err == nil {
+				return nil
+			}
+		}
+		if ctx.Err() != nil && phase == PhaseInit {
+			return nil // Init is cancelled on shutdown
+		}
+		a.degrade(idx, i, phase, err)
+		if ctx.Err() != nil {
+			return err
+		}
+
+		attempt++
+		if !sleep(ctx, backoff.Delay(attempt)) {
+			return nil
+		}
+	}
+}
+
+// sleep waits for d and reports whether it has elapsed before ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
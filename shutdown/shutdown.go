@@ -2,39 +2,229 @@ package shutdown
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/oomamontov/grace/pkg/itertool"
 	"github.com/oomamontov/grace/pkg/optional"
 	"github.com/oomamontov/grace/shutdown/task"
-	"golang.org/x/sync/errgroup"
+	"iter"
+	"log/slog"
 	"os"
-	"os/signal"
 	"slices"
+	"strings"
 	"syscall"
+	"time"
 )
 
+// Phase is a stage of the application lifecycle.
+type Phase string
+
+const (
+	PhaseInit     Phase = "init"
+	PhaseRun      Phase = "run"
+	PhaseShutdown Phase = "shutdown" // shutdown request, between run and stop; reported only in lifecycle records
+	PhaseStop     Phase = "stop"
+	PhaseRollback Phase = "rollback" // closing initialized tasks after failed initialization
+)
+
+// errorValue returns representation of err for structured logging and JSON:
+// err itself if it implements both slog.LogValuer and json.Marshaler, representations of joined errors
+// or error message otherwise.
+func errorValue(err error) any {
+	switch e := err.(type) {
+	case interface {
+		slog.LogValuer
+		json.Marshaler
+	}:
+		return e
+	case interface{ Unwrap() []error }:
+		inner := e.Unwrap()
+		res := make([]any, 0, len(inner))
+		for _, err := range inner {
+			res = append(res, errorValue(err))
+		}
+		return res
+	default:
+		return err.Error()
+	}
+}
+
+// LayerError reports an error of a layer.
 type LayerError struct {
-	Name  optional.Value[string]
-	Inner error
+	Name           optional.Value[string]
+	Index          int // index of the layer in order of registration
+	Phase          Phase
+	DuringShutdown bool          // whether the error occurred after shutdown had started
+	Elapsed        time.Duration // time since the application started
+	Inner          error
 }
 
 func (e LayerError) Error() string {
+	phase := e.Phase
+	if phase == "" {
+		phase = PhaseRun
+	}
 	if name, ok := e.Name.Get(); ok {
-		return fmt.Sprintf("run layer %q: %s", name, e.Inner.Error())
+		return fmt.Sprintf("%s layer %q: %s", phase, name, e.Inner.Error())
 	}
-	return fmt.Sprintf("run layer: %s", e.Inner.Error())
+	return fmt.Sprintf("%s layer: %s", phase, e.Inner.Error())
 }
 
 func (e LayerError) Unwrap() error {
 	return e.Inner
 }
 
+func (e LayerError) layer() string {
+	return LayerInfo{Index: e.Index, Name: e.Name}.String()
+}
+
+func (e LayerError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("layer", e.layer()),
+		slog.Int("index", e.Index),
+		slog.String("phase", string(e.Phase)),
+		slog.Bool("during_shutdown", e.DuringShutdown),
+		slog.Duration("elapsed", e.Elapsed),
+		slog.Any("error", errorValue(e.Inner)),
+	)
+}
+
+func (e LayerError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Layer          string        `json:"layer"`
+		Index          int           `json:"index"`
+		Phase          Phase         `json:"phase"`
+		DuringShutdown bool          `json:"during_shutdown"`
+		Elapsed        time.Duration `json:"elapsed"`
+		Error          any           `json:"error"`
+	}{
+		Layer:          e.layer(),
+		Index:          e.Index,
+		Phase:          e.Phase,
+		DuringShutdown: e.DuringShutdown,
+		Elapsed:        e.Elapsed,
+		Error:          errorValue(e.Inner),
+	})
+}
+
+// TaskError reports an error of a single task keeping identity of the task.
+// Errors of all failed tasks are collected into the error returned by Run, see TaskErrors.
+type TaskError struct {
+	Layer optional.Value[string]
+	Task  string // unnamed tasks are reported by their runner type name and index within the layer
+	Err   error
+}
+
+func (e TaskError) Error() string {
+	return e.Err.Error()
+}
+
+func (e TaskError) Unwrap() error {
+	return e.Err
+}
+
+func (e TaskError) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 3)
+	if layer, ok := e.Layer.Get(); ok {
+		attrs = append(attrs, slog.String("layer", layer))
+	}
+	attrs = append(attrs,
+		slog.String("task", e.Task),
+		slog.Any("error", errorValue(e.Err)),
+	)
+	return slog.GroupValue(attrs...)
+}
+
+func (e TaskError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Layer string `json:"layer,omitempty"`
+		Task  string `json:"task"`
+		Error any    `json:"error"`
+	}{
+		Layer: e.Layer.GetOrDefault(),
+		Task:  e.Task,
+		Error: errorValue(e.Err),
+	})
+}
+
+// TaskErrors returns iterator over all task errors within err tree, in order of depth-first traversal.
+// Both single and multiple wrapped errors are traversed, as with errors.Is.
+func TaskErrors(err error) iter.Seq[TaskError] {
+	return func(yield func(TaskError) bool) {
+		walkTaskErrors(err, yield)
+	}
+}
+
+// walkTaskErrors calls yield on each task error within err tree and reports whether walking should continue.
+func walkTaskErrors(err error, yield func(TaskError) bool) bool {
+	switch e := err.(type) {
+	case nil:
+		return true
+	case TaskError:
+		return yield(e)
+	case interface{ Unwrap() error }:
+		return walkTaskErrors(e.Unwrap(), yield)
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if !walkTaskErrors(inner, yield) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 type Layer struct {
 	name            optional.Value[string]
 	tasks           []task.Task
 	backgroundTasks []task.Task
+	stopTimeout     optional.Value[time.Duration]
+	initTimeout     optional.Value[time.Duration]
+	awaitReady      bool
+	readyTimeout    optional.Value[time.Duration]
+	dependsOn       optional.Value[[]string] // if unset: depends on the previously registered layer
+}
+
+// allTasks returns layer tasks followed by background tasks with their indexes.
+func (l Layer) allTasks() iter.Seq2[int, task.Task] {
+	return func(yield func(int, task.Task) bool) {
+		i := 0
+		for t := range itertool.Concat(slices.Values(l.tasks), slices.Values(l.backgroundTasks)) {
+			if !yield(i, t) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// taskAt returns the i-th task of the layer, indexed as allTasks.
+func (l Layer) taskAt(i int) task.Task {
+	if i < len(l.tasks) {
+		return l.tasks[i]
+	}
+	return l.backgroundTasks[i-len(l.tasks)]
+}
+
+// taskError returns err of the i-th task of the layer, indexed as allTasks, wrapped into TaskError.
+func (l Layer) taskError(i int, err error) TaskError {
+	return TaskError{
+		Layer: l.name,
+		Task:  l.taskName(i),
+		Err:   err,
+	}
 }
 
+// taskName returns name of the i-th task of the layer, indexed as allTasks.
+// Unnamed tasks are reported by their runner type name and index within the layer, e.g. "kv.Storage#0".
+func (l Layer) taskName(i int) string {
+	t := l.taskAt(i)
+	return t.Name().Or(fmt.Sprintf("%s#%d", t.TypeName(), i))
+}
+
+// WithBackgroundTasks adds background tasks running alongside main tasks of the layer.
+// On shutdown the layer is considered stopped only after its background tasks return,
+// even if the layer has no main tasks. Use WithLayerStopTimeout to limit waiting.
 func WithBackgroundTasks(rs ...task.Runner) func(*Layer) {
 	return func(layer *Layer) {
 		tasks := make([]task.Task, 0, len(rs))
@@ -55,6 +245,40 @@ func WithLayerName(name string) func(*Layer) {
 	}
 }
 
+// WithLayerStopTimeout limits time the layer is waited for to stop after cancellation.
+// When exceeded, the layer is abandoned and shutdown proceeds with the next layer.
+func WithLayerStopTimeout(d time.Duration) func(*Layer) {
+	return func(layer *Layer) {
+		layer.stopTimeout.Set(d)
+	}
+}
+
+// WithLayerInitTimeout limits time of the layer initialization.
+// When exceeded, startup fails with StartupTimeoutError naming tasks still initializing.
+func WithLayerInitTimeout(d time.Duration) func(*Layer) {
+	return func(layer *Layer) {
+		layer.initTimeout.Set(d)
+	}
+}
+
+// WithAwaitReady makes layers depending on the layer start Run only after every task of the layer
+// signals readiness with task.ReadyFuncFromContext or returns. The application is reported ready
+// only after all such layers are ready.
+func WithAwaitReady() func(*Layer) {
+	return func(layer *Layer) {
+		layer.awaitReady = true
+	}
+}
+
+// WithLayerReadyTimeout limits time the layer awaiting readiness is waited for to become ready, see WithAwaitReady.
+// When exceeded, startup fails with ReadyTimeoutError naming tasks not ready and the application is stopped:
+// the layer error and the error returned by Run are reported with PhaseInit.
+func WithLayerReadyTimeout(d time.Duration) func(*Layer) {
+	return func(layer *Layer) {
+		layer.readyTimeout.Set(d)
+	}
+}
+
 func NewLayer(rs []task.Runner, opts ...func(*Layer)) Layer {
 	tasks := make([]task.Task, 0, len(rs))
 	for _, r := range rs {
@@ -73,8 +297,22 @@ func NewLayer(rs []task.Runner, opts ...func(*Layer)) Layer {
 
 type Config struct {
 	layers                  []Layer
-	signals                 optional.Value[[]os.Signal] // default: os.Interrupt, syscall.SIGTERM
-	fallibleBackgroundTasks optional.Value[bool]        // default: false; if unset: false
+	signals                 optional.Value[[]os.Signal]   // default: os.Interrupt, syscall.SIGTERM
+	fallibleBackgroundTasks optional.Value[bool]          // default: false; if unset: false
+	shutdownTimeout         optional.Value[time.Duration] // default: unset; if unset: no timeout
+	taskStopTimeout         optional.Value[time.Duration] // default: 30s; if unset: no timeout
+	startupTimeout          optional.Value[time.Duration] // default: unset; if unset: no timeout
+	preStopDelay            optional.Value[time.Duration] // default: unset; if unset: no delay
+	healthCheckInterval     optional.Value[time.Duration] // default: 10s; if unset: no health checks
+	healthCheckTimeout      optional.Value[time.Duration] // default: 5s; if unset: no timeout
+	forceStopSignals        optional.Value[int]           // default: 2; if unset: never force
+	forceStopHook           optional.Value[func(error)]   // default: unset; if unset: no hook
+	forceExitCode           optional.Value[int]           // default: unset; if unset: Run returns
+	repanic                 optional.Value[bool]          // default: false; if unset: false
+	optionalTaskBackoff     optional.Value[task.Backoff]  // default: unset; if unset: see task.Backoff
+	observer                optional.Value[Observer]      // default: unset; if unset: NopObserver
+	logger                  optional.Value[*slog.Logger]  // default: unset; if unset: no logging
+	logLevels               optional.Value[LogLevels]     // default: unset; if unset: see LogLevels
 }
 
 // New returns empty shutdown config.
@@ -86,6 +324,11 @@ func New() Config {
 func (c Config) WithDefaultValues() Config {
 	c.signals.SetIfUnset([]os.Signal{os.Interrupt, syscall.SIGTERM})
 	c.fallibleBackgroundTasks.SetIfUnset(false)
+	c.forceStopSignals.SetIfUnset(2)
+	c.repanic.SetIfUnset(false)
+	c.taskStopTimeout.SetIfUnset(30 * time.Second)
+	c.healthCheckInterval.SetIfUnset(10 * time.Second)
+	c.healthCheckTimeout.SetIfUnset(5 * time.Second)
 	return c
 }
 
@@ -99,6 +342,67 @@ func (c Config) WithFallibleBackgroundTasks(allowed bool) Config {
 	return c
 }
 
+// WithShutdownTimeout limits total time of stopping all layers.
+// When exceeded, remaining layers are cancelled without waiting for them to stop.
+func (c Config) WithShutdownTimeout(d time.Duration) Config {
+	c.shutdownTimeout.Set(d)
+	return c
+}
+
+// WithTaskStopTimeout limits time of each task.Stopper Stop call.
+// Stop context is also limited by the layer stop timeout and the shutdown timeout, whichever expires first.
+// Unlike them, it does not abandon the layer: Run of the task is still waited for after Stop returns.
+// Default: 30s with WithDefaultValues, unlimited otherwise.
+func (c Config) WithTaskStopTimeout(d time.Duration) Config {
+	c.taskStopTimeout.Set(d)
+	return c
+}
+
+// WithStartupTimeout limits total time of initializing all layers.
+// When exceeded, startup fails with StartupTimeoutError naming tasks still initializing.
+func (c Config) WithStartupTimeout(d time.Duration) Config {
+	c.startupTimeout.Set(d)
+	return c
+}
+
+// WithPreStopDelay sets delay between interrupt signal or shutdown request and stopping of layers,
+// e.g. to let load balancers stop routing traffic to the application. App.Ready and App.ReadinessHandler
+// report the application is not ready during the delay. Another interrupt signal or task failure ends the delay
+// early; the signal is counted to force stop, see WithForceStopSignals.
+// The delay is skipped on shutdown caused by task failure.
+func (c Config) WithPreStopDelay(d time.Duration) Config {
+	c.preStopDelay.Set(d)
+	return c
+}
+
+// WithForceStopSignals sets the number of interrupt signals forcing shutdown.
+// On the n-th signal remaining graceful steps are skipped and all layers are cancelled at once.
+// Non-positive n disables force stop.
+func (c Config) WithForceStopSignals(n int) Config {
+	c.forceStopSignals.Set(n)
+	return c
+}
+
+// WithForceStopHook sets hook called with the resulting error when shutdown is forced.
+func (c Config) WithForceStopHook(hook func(error)) Config {
+	c.forceStopHook.Set(hook)
+	return c
+}
+
+// WithForceExitCode makes Run exit the process with provided code when shutdown is forced.
+// Exit happens after force stop hook is called.
+func (c Config) WithForceExitCode(code int) Config {
+	c.forceExitCode.Set(code)
+	return c
+}
+
+// WithRepanic makes Run and App.Wait panic with task.PanicError after all layers are stopped
+// if any runner has panicked.
+func (c Config) WithRepanic(enabled bool) Config {
+	c.repanic.Set(enabled)
+	return c
+}
+
 // Register registers individual runners to run on Run call.
 // Runners provided within single Register call will be initialized and stopped in parallel.
 // Runners provided within multiple different Register calls will be initialized and stopped sequentially.
@@ -119,8 +423,12 @@ func (c Config) RegisterLayer(layer Layer) Config {
 	return c
 }
 
+// RunError reports an error of the application returned by Run.
 type RunError struct {
-	Inner error
+	Phase   Phase         // PhaseInit if the application failed to start, PhaseRun otherwise
+	Elapsed time.Duration // time since the application started
+	Inner   error
+	Cause   error // cause of shutdown, if shutdown has started; see App.Cause
 }
 
 func (e RunError) Error() string {
@@ -131,8 +439,44 @@ func (e RunError) Unwrap() error {
 	return e.Inner
 }
 
+func (e RunError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("phase", string(e.Phase)),
+		slog.Duration("elapsed", e.Elapsed),
+	}
+	if e.Cause != nil {
+		attrs = append(attrs, slog.String("cause", e.Cause.Error()))
+	}
+	attrs = append(attrs, slog.Any("error", errorValue(e.Inner)))
+	return slog.GroupValue(attrs...)
+}
+
+func (e RunError) MarshalJSON() ([]byte, error) {
+	var cause string
+	if e.Cause != nil {
+		cause = e.Cause.Error()
+	}
+	return json.Marshal(struct {
+		Phase   Phase         `json:"phase"`
+		Elapsed time.Duration `json:"elapsed"`
+		Cause   string        `json:"cause,omitempty"`
+		Error   any           `json:"error"`
+	}{
+		Phase:   e.Phase,
+		Elapsed: e.Elapsed,
+		Cause:   cause,
+		Error:   errorValue(e.Inner),
+	})
+}
+
+// BackgroundTaskError reports a failure of a background task of a layer.
 type BackgroundTaskError struct {
-	Inner error
+	Layer          optional.Value[string]
+	Index          int // index of the layer in order of registration
+	Phase          Phase
+	DuringShutdown bool          // whether the error occurred after shutdown had started
+	Elapsed        time.Duration // time since the application started
+	Inner          error
 }
 
 func (e BackgroundTaskError) Error() string {
@@ -143,109 +487,185 @@ func (e BackgroundTaskError) Unwrap() error {
 	return e.Inner
 }
 
-// Run runs Init and then Run on registered runners.
-// Provided context might be used to stop initialization and return on Init stage, but not on Run stage.
-// If one runner returns error, all other runners are stopped forcefully.
-func (c Config) Run(ctx context.Context) error {
-	stopCh := make(chan os.Signal, 1)
-	signal.Notify(stopCh, c.signals.GetOrDefault()...)
+func (e BackgroundTaskError) layer() string {
+	return LayerInfo{Index: e.Index, Name: e.Layer}.String()
+}
 
-	for _, layer := range c.layers {
-		if err := ctx.Err(); err != nil { // do not run Init if context is cancelled
-			return RunError{Inner: err}
-		}
-		initEg, initCtx := errgroup.WithContext(ctx)
-		for t := range itertool.Concat(slices.Values(layer.tasks), slices.Values(layer.backgroundTasks)) {
-			initEg.Go(func() error {
-				return t.Init(initCtx)
-			})
-		}
-		if err := initEg.Wait(); err != nil {
-			return RunError{
-				Inner: LayerError{
-					Name:  layer.name,
-					Inner: err,
-				},
-			}
-		}
-	}
+func (e BackgroundTaskError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("background", true),
+		slog.String("layer", e.layer()),
+		slog.Int("index", e.Index),
+		slog.String("phase", string(e.Phase)),
+		slog.Bool("during_shutdown", e.DuringShutdown),
+		slog.Duration("elapsed", e.Elapsed),
+		slog.Any("error", errorValue(e.Inner)),
+	)
+}
 
-	g, runCtx := errgroup.WithContext(context.WithoutCancel(ctx))
-	if err := ctx.Err(); err != nil { // do not run if context is cancelled before goroutines start
-		return RunError{Inner: err}
-	}
+func (e BackgroundTaskError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Background     bool          `json:"background"`
+		Layer          string        `json:"layer"`
+		Index          int           `json:"index"`
+		Phase          Phase         `json:"phase"`
+		DuringShutdown bool          `json:"during_shutdown"`
+		Elapsed        time.Duration `json:"elapsed"`
+		Error          any           `json:"error"`
+	}{
+		Background:     true,
+		Layer:          e.layer(),
+		Index:          e.Index,
+		Phase:          e.Phase,
+		DuringShutdown: e.DuringShutdown,
+		Elapsed:        e.Elapsed,
+		Error:          errorValue(e.Inner),
+	})
+}
 
-	// ctx cancellation does nothing from now on
+// OptionalTaskError reports failure of an optional task, which degrades the application without stopping it.
+// Only the first and the last failures of a task are reported, Failure tells how many times the task has failed.
+type OptionalTaskError struct {
+	Failure int // number of the failure of the task, starting from 1
+	Inner   error
+}
 
-	layerStopped := make(chan struct{})
-	cancelFuncs := make([]context.CancelFunc, 0, len(c.layers))
+func (e OptionalTaskError) Error() string {
+	return fmt.Sprintf("run optional task (failure %d): %s", e.Failure, e.Inner.Error())
+}
 
-	for _, layer := range c.layers {
-		localCtx, cancel := context.WithCancel(runCtx)
-		defer cancel()
-		lg, layerCtx := errgroup.WithContext(localCtx)
+func (e OptionalTaskError) Unwrap() error {
+	return e.Inner
+}
 
-		cancelFuncs = append(cancelFuncs, cancel)
+func (e OptionalTaskError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("optional", true),
+		slog.Int("failure", e.Failure),
+		slog.Any("error", errorValue(e.Inner)),
+	)
+}
 
-		g.Go(func() error {
-			if len(layer.tasks) == 0 {
-				// localCtx is not cancelled after successful wait
-				context.AfterFunc(localCtx, func() {
-					layerStopped <- struct{}{} // will be executed after shutdown command on layer cancel command
-				})
-			} else {
-				defer func() { layerStopped <- struct{}{} }() // will be executed after lg.Wait()
-			}
+func (e OptionalTaskError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Optional bool `json:"optional"`
+		Failure  int  `json:"failure"`
+		Error    any  `json:"error"`
+	}{
+		Optional: true,
+		Failure:  e.Failure,
+		Error:    errorValue(e.Inner),
+	})
+}
 
-			for _, t := range layer.backgroundTasks {
-				lg.Go(func() error {
-					if err := t.Run(layerCtx); err != nil && !c.fallibleBackgroundTasks.GetOrDefault() {
-						return BackgroundTaskError{Inner: err}
-					}
-					return nil
-				})
-			}
+// StopTimeoutError reports tasks of a layer that did not return before the stop timeout expired.
+type StopTimeoutError struct {
+	Tasks []string
+}
 
-			for _, t := range layer.tasks {
-				lg.Go(func() error {
-					if err := t.Run(layerCtx); err != nil {
-						return err
-					}
-					return nil
-				})
-			}
+func (e StopTimeoutError) Error() string {
+	return fmt.Sprintf("stop timeout exceeded, tasks still running: %s", strings.Join(e.Tasks, ", "))
+}
 
-			if err := lg.Wait(); err != nil {
-				return LayerError{
-					Name:  layer.name,
-					Inner: err,
-				}
-			}
-			return nil
-		})
-	}
-
-	g.Go(func() error {
-		select {
-		case <-stopCh:
-			for _, f := range slices.Backward(cancelFuncs) {
-				f()
-				select {
-				case <-layerStopped:
-					continue
-				case <-runCtx.Done():
-					return nil
-				}
-			}
-		case <-runCtx.Done():
-			return nil
-		}
-		return nil
-	})
+// StartupTimeoutError reports tasks of a layer that were still initializing when its init timeout
+// or startup timeout expired.
+type StartupTimeoutError struct {
+	Tasks []string
+}
+
+func (e StartupTimeoutError) Error() string {
+	return fmt.Sprintf("startup timeout exceeded, tasks still initializing: %s", strings.Join(e.Tasks, ", "))
+}
+
+// ReadyTimeoutError reports tasks of a layer that did not signal readiness before its ready timeout expired.
+type ReadyTimeoutError struct {
+	Tasks []string
+}
+
+func (e ReadyTimeoutError) Error() string {
+	return fmt.Sprintf("ready timeout exceeded, tasks not ready: %s", strings.Join(e.Tasks, ", "))
+}
+
+// ForcedStopError reports tasks of a layer that were still running when shutdown was forced.
+type ForcedStopError struct {
+	Signal os.Signal
+	Tasks  []string
+}
+
+func (e ForcedStopError) Error() string {
+	return fmt.Sprintf("shutdown forced by %s signal, tasks still running: %s", e.Signal, strings.Join(e.Tasks, ", "))
+}
+
+// InitInterruptedError reports that initialization was cancelled by interrupt signal or shutdown request.
+type InitInterruptedError struct {
+	Cause error
+}
 
-	if err := g.Wait(); err != nil {
-		return RunError{Inner: err}
+func (e InitInterruptedError) Error() string {
+	return fmt.Sprintf("initialization interrupted: %s", e.Cause.Error())
+}
+
+func (e InitInterruptedError) Unwrap() error {
+	return e.Cause
+}
+
+// SignalReceived is a shutdown cause reported when an interrupt signal is received.
+type SignalReceived struct {
+	Signal os.Signal
+}
+
+func (c SignalReceived) Error() string {
+	return fmt.Sprintf("received %s signal", c.Signal)
+}
+
+// Requested is a shutdown cause reported when shutdown is requested with App.Shutdown.
+type Requested struct {
+	Reason string
+}
+
+func (c Requested) Error() string {
+	return fmt.Sprintf("shutdown requested: %s", c.Reason)
+}
+
+// TaskFailed is a shutdown cause reported when a task returns an error.
+type TaskFailed struct {
+	Layer optional.Value[string]
+	Task  string
+	Err   error
+}
+
+func (c TaskFailed) Error() string {
+	if layer, ok := c.Layer.Get(); ok {
+		return fmt.Sprintf("task %q of layer %q failed: %s", c.Task, layer, c.Err.Error())
 	}
+	return fmt.Sprintf("task %q failed: %s", c.Task, c.Err.Error())
+}
 
-	return nil
+func (c TaskFailed) Unwrap() error {
+	return c.Err
+}
+
+// Run runs Init and then Run on registered runners and waits for them to stop.
+// Layers are initialized in order of their dependencies and stopped in reverse order, see RegisterNode.
+// Unknown dependencies and dependency cycles are reported before any runner is initialized.
+// Provided context might be used to stop initialization and return on Init stage, but not on Run stage.
+// Interrupt signal received during initialization cancels Init context, remaining layers are not initialized
+// and InitInterruptedError is returned.
+// If initialization fails or is cancelled, already initialized runners implementing task.Closer
+// are closed in reverse order and Close errors are joined into the returned error.
+// Layers awaiting readiness, see WithAwaitReady, hold Run of layers depending on them until their tasks are ready.
+// If one runner returns error, all layers are stopped in reverse order just like on interrupt signal,
+// and the error is reported first. Errors of all failed tasks are collected into the returned error
+// as TaskError, see TaskErrors.
+// Failures of optional tasks, see task.WithCriticality, do not stop the application: the task is retried
+// in background, reported by App.Degraded meanwhile, and its first and last errors are reported as OptionalTaskError.
+// If layer does not stop within its stop timeout or shutdown timeout is exceeded,
+// the layer is abandoned and StopTimeoutError is reported for it.
+// If shutdown is forced by repeated interrupt signals, all layers are cancelled at once,
+// Run returns without waiting for them and ForcedStopError is reported for layers still running.
+// Panics in runners are recovered and handled as errors, see task.PanicError and WithRepanic.
+// Runners might use context.Cause on their context to get the cause of shutdown:
+// SignalReceived, Requested or TaskFailed.
+func (c Config) Run(ctx context.Context) error {
+	return c.Start(ctx).Wait()
 }
@@ -0,0 +1,1026 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/oomamontov/grace/shutdown/task"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+type funcRunner func(ctx context.Context) error
+
+func (f funcRunner) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+func failingRunner() funcRunner {
+	return func(_ context.Context) error {
+		return errTest
+	}
+}
+
+// recorder collects events reported by concurrently running tasks.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// get returns events recorded so far in order of recording.
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+func blockingRunner(release <-chan struct{}) funcRunner {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		<-release
+		return nil
+	}
+}
+
+func TestLayerStopTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+
+	stopped := make(chan struct{})
+	cfg := New().WithDefaultValues().
+		Register(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return nil
+		})).
+		RegisterLayer(NewLayer(
+			[]task.Runner{task.New(blockingRunner(release), task.WithName("stuck"))},
+			WithLayerName("top"),
+			WithLayerStopTimeout(10*time.Millisecond),
+		)).
+		Register(failingRunner())
+
+	err := cfg.Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+	var timeoutErr StopTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, []string{"stuck"}, timeoutErr.Tasks)
+	<-stopped
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+
+	cfg := New().WithDefaultValues().
+		WithShutdownTimeout(10*time.Millisecond).
+		Register(blockingRunner(release), blockingRunner(release)).
+		Register(failingRunner())
+
+	err := cfg.Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+	var timeoutErr StopTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, []string{"shutdown.funcRunner#0", "shutdown.funcRunner#1"}, timeoutErr.Tasks)
+}
+
+func TestForceStop(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	var hookErr error
+	cfg := New().WithDefaultValues().
+		WithInterruptSignals(syscall.SIGUSR1).
+		WithForceStopHook(func(err error) {
+			hookErr = err
+		}).
+		Register(blockingRunner(release)).
+		RegisterLayer(NewLayer(
+			[]task.Runner{task.New(funcRunner(func(ctx context.Context) error {
+				close(started)
+				<-ctx.Done()
+				close(cancelled)
+				<-release
+				return nil
+			}), task.WithName("stuck"))},
+			WithLayerName("top"),
+		))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cfg.Run(t.Context())
+	}()
+
+	<-started
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	<-cancelled
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	err := <-errCh
+	var forcedErr ForcedStopError
+	require.ErrorAs(t, err, &forcedErr)
+	require.Equal(t, syscall.SIGUSR1, forcedErr.Signal)
+	require.Equal(t, []string{"stuck"}, forcedErr.Tasks)
+	require.Equal(t, err, hookErr)
+}
+
+func TestAppShutdown(t *testing.T) {
+	t.Parallel()
+	var stopped recorder
+	runner := func(name string) task.Task {
+		return task.New(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped.record(name)
+			return nil
+		}), task.WithName(name))
+	}
+
+	app := New().WithDefaultValues().
+		Register(runner("storage")).
+		Register(runner("service")).
+		Register(runner("server")).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return app.State() == StateRunning
+	}, time.Second, time.Millisecond)
+
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+	require.Equal(t, StateStopped, app.State())
+	<-app.Done()
+	require.Equal(t, []string{"server", "service", "storage"}, stopped.get())
+}
+
+func causeRunner(causes chan<- error) funcRunner {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil
+	}
+}
+
+func TestShutdownCause(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requested", func(t *testing.T) {
+		t.Parallel()
+		causes := make(chan error, 1)
+		app := New().WithDefaultValues().
+			Register(causeRunner(causes)).
+			Start(t.Context())
+		require.Eventually(t, func() bool {
+			return app.State() == StateRunning
+		}, time.Second, time.Millisecond)
+		app.Shutdown("test")
+		require.NoError(t, app.Wait())
+		require.Equal(t, Requested{Reason: "test"}, <-causes)
+		require.Equal(t, Requested{Reason: "test"}, app.Cause())
+	})
+
+	t.Run("task failed", func(t *testing.T) {
+		t.Parallel()
+		causes := make(chan error, 1)
+		err := New().WithDefaultValues().
+			Register(causeRunner(causes)).
+			RegisterLayer(NewLayer(
+				[]task.Runner{task.New(failingRunner(), task.WithName("failing"))},
+				WithLayerName("top"),
+			)).
+			Run(t.Context())
+		require.ErrorIs(t, err, errTest)
+
+		var runErr RunError
+		require.ErrorAs(t, err, &runErr)
+		var taskFailed TaskFailed
+		require.ErrorAs(t, runErr.Cause, &taskFailed)
+		require.Equal(t, "failing", taskFailed.Task)
+		require.Equal(t, "top", taskFailed.Layer.ShouldGet())
+
+		cause := <-causes
+		require.ErrorAs(t, cause, &taskFailed)
+		require.ErrorIs(t, cause, errTest)
+	})
+}
+
+func TestFailureTeardownOrder(t *testing.T) {
+	t.Parallel()
+	var stopped recorder
+	record := stopped.record
+	runner := func(name string) funcRunner {
+		return func(ctx context.Context) error {
+			<-ctx.Done()
+			record(name)
+			return nil
+		}
+	}
+
+	serverStarted := make(chan struct{})
+	err := New().WithDefaultValues().
+		Register(runner("storage"), funcRunner(func(ctx context.Context) error {
+			<-serverStarted
+			record("failing")
+			return errTest
+		})).
+		Register(runner("service")).
+		Register(funcRunner(func(ctx context.Context) error {
+			close(serverStarted)
+			<-ctx.Done()
+			record("server")
+			return nil
+		})).
+		Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, []string{"failing", "server", "service", "storage"}, stopped.get())
+}
+
+type closerRunner struct {
+	name    string
+	initErr error
+	closed  func(name string)
+}
+
+func (r closerRunner) Init(_ context.Context) error {
+	return r.initErr
+}
+
+func (r closerRunner) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (r closerRunner) Close(_ context.Context) error {
+	r.closed(r.name)
+	return errors.New(r.name)
+}
+
+func TestInitRollback(t *testing.T) {
+	t.Parallel()
+	var closed recorder
+	err := New().WithDefaultValues().
+		Register(closerRunner{name: "storage", closed: closed.record}).
+		Register(closerRunner{name: "service", closed: closed.record}).
+		Register(closerRunner{name: "server", initErr: errTest, closed: closed.record}).
+		Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, []string{"service", "storage"}, closed.get())
+	require.ErrorContains(t, err, `close task "shutdown.closerRunner": service`)
+	require.ErrorContains(t, err, `close task "shutdown.closerRunner": storage`)
+}
+
+type initRunner struct {
+	init func(ctx context.Context) error
+	run  func(ctx context.Context) error
+}
+
+func (r initRunner) Init(ctx context.Context) error {
+	return r.init(ctx)
+}
+
+func (r initRunner) Run(ctx context.Context) error {
+	if r.run != nil {
+		return r.run(ctx)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestInitInterrupted(t *testing.T) {
+	t.Parallel()
+	var closed recorder
+	initStarted := make(chan struct{})
+	app := New().WithDefaultValues().
+		Register(closerRunner{name: "storage", closed: closed.record}).
+		Register(initRunner{init: func(ctx context.Context) error {
+			close(initStarted)
+			<-ctx.Done()
+			return nil
+		}}).
+		Register(initRunner{init: func(ctx context.Context) error {
+			t.Error("layer after interrupted one must not be initialized")
+			return nil
+		}}).
+		Start(t.Context())
+	<-initStarted
+	app.Shutdown("test")
+
+	err := app.Wait()
+	var interruptedErr InitInterruptedError
+	require.ErrorAs(t, err, &interruptedErr)
+	require.Equal(t, Requested{Reason: "test"}, interruptedErr.Cause)
+	require.Equal(t, []string{"storage"}, closed.get())
+}
+
+type serverRunner struct {
+	runCtx  context.Context
+	serving chan struct{}
+	stopped chan struct{}
+}
+
+func (r *serverRunner) Run(ctx context.Context) error {
+	r.runCtx = ctx
+	close(r.serving)
+	<-r.stopped
+	return nil
+}
+
+func (r *serverRunner) Stop(ctx context.Context) error {
+	defer close(r.stopped)
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("stop context has no deadline")
+	}
+	if r.runCtx.Err() != nil {
+		return errors.New("run context is cancelled before Stop")
+	}
+	return nil
+}
+
+func TestStopper(t *testing.T) {
+	t.Parallel()
+	server := &serverRunner{
+		serving: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	app := New().WithDefaultValues().
+		Register(server).
+		Start(t.Context())
+	<-server.serving
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+}
+
+func TestBackgroundOnlyLayerDrain(t *testing.T) {
+	t.Parallel()
+	var stopped recorder
+	storageStopped := make(chan struct{})
+	app := New().WithDefaultValues().
+		Register(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			close(storageStopped)
+			stopped.record("storage")
+			return nil
+		})).
+		RegisterLayer(NewLayer(nil, WithBackgroundTasks(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			select {
+			case <-storageStopped:
+				t.Error("storage must not be stopped before the cleaner returns")
+			default:
+			}
+			stopped.record("cleaner")
+			return nil
+		})))).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return app.State() == StateRunning
+	}, time.Second, time.Millisecond)
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+	require.Equal(t, []string{"cleaner", "storage"}, stopped.get())
+}
+
+func TestLayerStopOrder(t *testing.T) {
+	t.Parallel()
+	var stopped recorder
+	runner := func(name string) funcRunner {
+		return func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped.record(name)
+			return nil
+		}
+	}
+
+	app := New().WithDefaultValues().
+		RegisterLayer(NewLayer(
+			[]task.Runner{
+				runner("main"),
+				task.New(runner("first"), task.WithStopPriority(1)),
+			},
+			WithBackgroundTasks(runner("background")),
+		)).
+		Start(t.Context())
+	require.Eventually(t, func() bool {
+		return app.State() == StateRunning
+	}, time.Second, time.Millisecond)
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+	require.Equal(t, []string{"first", "main", "background"}, stopped.get())
+}
+
+func TestRestartLimitEscalation(t *testing.T) {
+	t.Parallel()
+	err := New().WithDefaultValues().
+		WithFallibleBackgroundTasks(true).
+		RegisterLayer(NewLayer(
+			[]task.Runner{causeRunner(make(chan error, 1))},
+			WithBackgroundTasks(task.New(failingRunner(), task.WithRestartPolicy(task.RestartPolicy{
+				Backoff:     task.Backoff{InitialBackoff: time.Millisecond},
+				MaxRestarts: 1,
+			}))),
+		)).
+		Run(t.Context())
+	require.ErrorIs(t, err, errTest)
+	require.ErrorAs(t, err, new(task.RestartLimitError))
+	var bgErr BackgroundTaskError
+	require.ErrorAs(t, err, &bgErr)
+	require.Equal(t, PhaseRun, bgErr.Phase)
+	require.False(t, bgErr.DuringShutdown)
+	require.Zero(t, bgErr.Index)
+	require.Positive(t, bgErr.Elapsed)
+}
+
+func TestRestartedTaskStop(t *testing.T) {
+	t.Parallel()
+	var runs atomic.Int32
+	restarted := make(chan struct{})
+	app := New().WithDefaultValues().
+		Register(task.New(funcRunner(func(ctx context.Context) error {
+			if runs.Add(1) == 1 {
+				return errTest
+			}
+			close(restarted)
+			<-ctx.Done()
+			return nil
+		}), task.WithRestartPolicy(task.RestartPolicy{
+			Backoff: task.Backoff{InitialBackoff: time.Millisecond},
+		}))).
+		Start(t.Context())
+	<-restarted
+	app.Shutdown("test")
+	require.NoError(t, app.Wait())
+}
+
+func TestStartupTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	hanging := initRunner{init: func(_ context.Context) error {
+		<-release
+		return nil
+	}}
+
+	t.Run("layer", func(t *testing.T) {
+		t.Parallel()
+		err := New().WithDefaultValues().
+			RegisterLayer(NewLayer(
+				[]task.Runner{task.New(hanging, task.WithName("hanging")), initRunner{init: func(_ context.Context) error {
+					return nil
+				}}},
+				WithLayerName("storage"),
+				WithLayerInitTimeout(10*time.Millisecond),
+			)).
+			Run(t.Context())
+		var timeoutErr StartupTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, []string{"hanging"}, timeoutErr.Tasks)
+		require.ErrorContains(t, err, `init layer "storage"`)
+	})
+
+	t.Run("startup", func(t *testing.T) {
+		t.Parallel()
+		err := New().WithDefaultValues().
+			WithStartupTimeout(10 * time.Millisecond).
+			Register(hanging).
+			Run(t.Context())
+		var timeoutErr StartupTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, []string{"shutdown.initRunner#0"}, timeoutErr.Tasks)
+	})
+}
+
+func TestPreStopDelay(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delay", func(t *testing.T) {
+		delay := 20 * time.Millisecond
+		cancelled := make(chan time.Time, 1)
+		app := New().WithDefaultValues().
+			WithPreStopDelay(delay).
+			Register(funcRunner(func(ctx context.Context) error {
+				<-ctx.Done()
+				cancelled <- time.Now()
+				return nil
+			})).
+			Start(t.Context())
+		require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+
+		start := time.Now()
+		app.Shutdown("test")
+		require.False(t, app.Ready())
+		require.NoError(t, app.Wait())
+		require.GreaterOrEqual(t, (<-cancelled).Sub(start), delay)
+	})
+
+	// subtests sending signals are not parallel, so signals are not received by another subtest
+	secondSignal := func(t *testing.T, cfg Config, runner task.Runner) error {
+		app := cfg.
+			WithInterruptSignals(syscall.SIGUSR2).
+			WithPreStopDelay(time.Hour).
+			Register(runner).
+			Start(t.Context())
+		require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+		require.Eventually(t, func() bool {
+			return app.State() == StateStopping
+		}, time.Second, time.Millisecond)
+		require.False(t, app.Ready())
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+		return app.Wait()
+	}
+
+	t.Run("second signal", func(t *testing.T) {
+		err := secondSignal(t, New().WithDefaultValues().WithForceStopSignals(3), funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}))
+		require.NoError(t, err)
+	})
+
+	t.Run("second signal forces stop", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		err := secondSignal(t, New().WithDefaultValues(), task.New(blockingRunner(release), task.WithName("stuck")))
+		var forcedErr ForcedStopError
+		require.ErrorAs(t, err, &forcedErr)
+		require.Equal(t, syscall.SIGUSR2, forcedErr.Signal)
+	})
+
+	t.Run("task failure", func(t *testing.T) {
+		t.Parallel()
+		stopping := make(chan struct{})
+		app := New().WithDefaultValues().
+			WithPreStopDelay(time.Hour).
+			Register(funcRunner(func(ctx context.Context) error {
+				<-stopping
+				return errTest
+			})).
+			Start(t.Context())
+		require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+		app.Shutdown("test")
+		close(stopping)
+		require.ErrorIs(t, app.Wait(), errTest)
+	})
+}
+
+func TestAwaitReady(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ready", func(t *testing.T) {
+		t.Parallel()
+		running, release := make(chan struct{}), make(chan struct{})
+		upperStarted := make(chan struct{})
+		app := New().WithDefaultValues().
+			RegisterLayer(NewLayer(
+				[]task.Runner{funcRunner(func(ctx context.Context) error {
+					close(running)
+					<-release
+					task.ReadyFuncFromContext(ctx)()
+					<-ctx.Done()
+					return nil
+				})},
+				WithAwaitReady(),
+			)).
+			Register(funcRunner(func(ctx context.Context) error {
+				close(upperStarted)
+				<-ctx.Done()
+				return nil
+			})).
+			Start(t.Context())
+
+		<-running
+		require.Equal(t, StateInitializing, app.State())
+		select {
+		case <-upperStarted:
+			t.Fatal("upper layer must not start before the lower one is ready")
+		default:
+		}
+
+		close(release)
+		<-upperStarted
+		require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+		app.Shutdown("test")
+		require.NoError(t, app.Wait())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+		err := New().WithDefaultValues().
+			RegisterLayer(NewLayer(
+				[]task.Runner{task.New(funcRunner(func(ctx context.Context) error {
+					<-ctx.Done()
+					return nil
+				}), task.WithName("listener"))},
+				WithLayerName("transport"),
+				WithAwaitReady(),
+				WithLayerReadyTimeout(10*time.Millisecond),
+			)).
+			Register(funcRunner(func(_ context.Context) error {
+				t.Error("upper layer must not start if the lower one is not ready")
+				return nil
+			})).
+			Run(t.Context())
+		var readyErr ReadyTimeoutError
+		require.ErrorAs(t, err, &readyErr)
+		require.Equal(t, []string{"listener"}, readyErr.Tasks)
+		require.ErrorContains(t, err, `init layer "transport"`)
+		require.Equal(t, PhaseInit, err.(RunError).Phase)
+		require.Equal(t, OutcomeInitFailure, OutcomeOf(err))
+	})
+}
+
+func TestPanicTeardown(t *testing.T) {
+	t.Parallel()
+	cfg := func(closed chan<- struct{}) Config {
+		return New().WithDefaultValues().
+			Register(funcRunner(func(ctx context.Context) error {
+				<-ctx.Done()
+				close(closed)
+				return nil
+			})).
+			Register(funcRunner(func(_ context.Context) error {
+				panic("boom")
+			}))
+	}
+
+	t.Run("recover", func(t *testing.T) {
+		t.Parallel()
+		closed := make(chan struct{})
+		err := cfg(closed).Run(t.Context())
+		var panicErr task.PanicError
+		require.ErrorAs(t, err, &panicErr)
+		require.Equal(t, "boom", panicErr.Value)
+		var cause TaskFailed
+		require.ErrorAs(t, err.(RunError).Cause, &cause)
+		<-closed
+	})
+
+	t.Run("repanic", func(t *testing.T) {
+		t.Parallel()
+		closed := make(chan struct{})
+		app := cfg(closed).WithRepanic(true).Start(t.Context())
+		defer func() {
+			panicErr, ok := recover().(task.PanicError)
+			require.True(t, ok)
+			require.Equal(t, "boom", panicErr.Value)
+			<-closed
+		}()
+		_ = app.Wait()
+		t.Error("Wait must panic")
+	})
+}
+
+func TestAggregateErrors(t *testing.T) {
+	t.Parallel()
+	errHTTP, errGRPC := errors.New("http error"), errors.New("grpc error")
+	taskNames := func(err error) []string {
+		var names []string
+		for taskErr := range TaskErrors(err) {
+			names = append(names, taskErr.Task)
+		}
+		return names
+	}
+
+	t.Run("run", func(t *testing.T) {
+		t.Parallel()
+		var started sync.WaitGroup
+		started.Add(2)
+		failing := func(err error) funcRunner {
+			return func(_ context.Context) error {
+				started.Done()
+				started.Wait()
+				return err
+			}
+		}
+		err := New().WithDefaultValues().
+			Register(
+				task.New(failing(errHTTP), task.WithName("http")),
+				task.New(failing(errGRPC), task.WithName("grpc")),
+			).
+			Run(t.Context())
+		require.ErrorIs(t, err, errHTTP)
+		require.ErrorIs(t, err, errGRPC)
+		require.ElementsMatch(t, []string{"http", "grpc"}, taskNames(err))
+	})
+
+	t.Run("init", func(t *testing.T) {
+		t.Parallel()
+		var started sync.WaitGroup
+		started.Add(2)
+		failing := func(err error) initRunner {
+			return initRunner{init: func(_ context.Context) error {
+				started.Done()
+				started.Wait()
+				return err
+			}}
+		}
+		err := New().WithDefaultValues().
+			RegisterLayer(NewLayer(
+				[]task.Runner{failing(errHTTP), failing(errGRPC)},
+				WithLayerName("storage"),
+			)).
+			Run(t.Context())
+		require.ErrorIs(t, err, errHTTP)
+		require.ErrorIs(t, err, errGRPC)
+		require.ElementsMatch(t, []string{"shutdown.initRunner#0", "shutdown.initRunner#1"}, taskNames(err))
+		for taskErr := range TaskErrors(err) {
+			require.Equal(t, "storage", taskErr.Layer.ShouldGet())
+		}
+	})
+}
+
+func TestErrorModel(t *testing.T) {
+	t.Parallel()
+	err := New().WithDefaultValues().
+		Register(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})).
+		RegisterLayer(NewLayer(
+			[]task.Runner{task.New(failingRunner(), task.WithName("server"))},
+			WithLayerName("api"),
+		)).
+		Run(t.Context())
+
+	var runErr RunError
+	require.ErrorAs(t, err, &runErr)
+	require.Equal(t, PhaseRun, runErr.Phase)
+	var layerErr LayerError
+	require.ErrorAs(t, err, &layerErr)
+	require.Equal(t, 1, layerErr.Index)
+	require.Equal(t, PhaseRun, layerErr.Phase)
+	require.False(t, layerErr.DuringShutdown)
+	require.LessOrEqual(t, layerErr.Elapsed, runErr.Elapsed)
+
+	data, jsonErr := json.Marshal(err)
+	require.NoError(t, jsonErr)
+	var decoded struct {
+		Phase Phase `json:"phase"`
+		Error []struct {
+			Layer string `json:"layer"`
+			Phase Phase  `json:"phase"`
+			Error struct {
+				Task  string `json:"task"`
+				Error struct {
+					Action string `json:"action"`
+					Error  string `json:"error"`
+				} `json:"error"`
+			} `json:"error"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, PhaseRun, decoded.Phase)
+	require.Len(t, decoded.Error, 1)
+	require.Equal(t, "api", decoded.Error[0].Layer)
+	require.Equal(t, "server", decoded.Error[0].Error.Task)
+	require.Equal(t, task.ActionRun, decoded.Error[0].Error.Error.Action)
+	require.Equal(t, errTest.Error(), decoded.Error[0].Error.Error.Error)
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("run failed", "error", err)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, string(PhaseRun), record["error"].(map[string]any)["phase"])
+}
+
+func TestOptionalTask(t *testing.T) {
+	t.Parallel()
+	errCache := errors.New("cache error")
+	var inits atomic.Int32
+	recovered := make(chan struct{})
+	app := New().WithDefaultValues().
+		WithOptionalTaskBackoff(task.Backoff{InitialBackoff: time.Millisecond}).
+		Register(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})).
+		RegisterLayer(NewLayer([]task.Runner{task.New(
+			initRunner{
+				init: func(_ context.Context) error {
+					if inits.Add(1) < 3 {
+						return errCache
+					}
+					return nil
+				},
+				run: func(ctx context.Context) error {
+					close(recovered)
+					<-ctx.Done()
+					return nil
+				},
+			},
+			task.WithName("cache"),
+			task.WithCriticality(task.Optional),
+		)}, WithLayerName("cache"))).
+		Start(t.Context())
+
+	<-recovered
+	require.Equal(t, StateRunning, app.State())
+	require.Empty(t, app.Degraded())
+
+	app.Shutdown("test")
+	err := app.Wait()
+	require.ErrorIs(t, err, errCache)
+	var optErr OptionalTaskError
+	require.ErrorAs(t, err, &optErr)
+	var layerErr LayerError
+	require.ErrorAs(t, err, &layerErr)
+	require.Equal(t, PhaseInit, layerErr.Phase)
+	var failures int
+	for taskErr := range TaskErrors(err) {
+		require.Equal(t, "cache", taskErr.Task)
+		failures++
+	}
+	require.Equal(t, 2, failures)
+	require.Equal(t, OutcomeDegraded, OutcomeOf(err))
+}
+
+func TestOptionalTaskDegraded(t *testing.T) {
+	t.Parallel()
+	app := New().WithDefaultValues().
+		WithOptionalTaskBackoff(task.Backoff{InitialBackoff: time.Hour}).
+		Register(
+			funcRunner(func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			}),
+			task.New(failingRunner(), task.WithName("metrics"), task.WithCriticality(task.Optional)),
+		).
+		Start(t.Context())
+
+	require.Eventually(t, func() bool {
+		return len(app.Degraded()) == 1
+	}, time.Second, time.Millisecond)
+	degraded := app.Degraded()[0]
+	require.Equal(t, "metrics", degraded.Task)
+	require.Equal(t, PhaseRun, degraded.Phase)
+	require.ErrorIs(t, degraded.Err, errTest)
+	require.Equal(t, StateRunning, app.State())
+	require.True(t, app.Ready())
+
+	app.Shutdown("test")
+	err := app.Wait()
+	require.ErrorIs(t, err, errTest)
+	require.ErrorAs(t, err, new(OptionalTaskError))
+}
+
+func TestOptionalTaskBackoff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("init", func(t *testing.T) {
+		t.Parallel()
+		var inits atomic.Int32
+		app := New().WithDefaultValues().
+			WithOptionalTaskBackoff(task.Backoff{InitialBackoff: time.Hour}).
+			Register(funcRunner(func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			})).
+			Register(task.New(
+				initRunner{init: func(_ context.Context) error {
+					inits.Add(1)
+					return errTest
+				}},
+				task.WithName("opt"),
+				task.WithCriticality(task.Optional),
+			)).
+			Start(t.Context())
+
+		require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+		require.Len(t, app.Degraded(), 1)
+		app.Shutdown("test")
+		require.ErrorAs(t, app.Wait(), new(OptionalTaskError))
+		require.Equal(t, int32(1), inits.Load(), "failed Init must not be retried before backoff")
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		t.Parallel()
+		var runs atomic.Int32
+		app := New().WithDefaultValues().
+			WithOptionalTaskBackoff(task.Backoff{InitialBackoff: time.Millisecond, Multiplier: 1e6}).
+			Register(funcRunner(func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			})).
+			Register(task.New(
+				funcRunner(func(_ context.Context) error {
+					runs.Add(1)
+					return errTest
+				}),
+				task.WithName("opt"),
+				task.WithCriticality(task.Optional),
+			)).
+			Start(t.Context())
+
+		// backoff grows to the maximum after the first retry unless it is reset by started Run
+		require.Eventually(t, func() bool {
+			return runs.Load() >= 3
+		}, time.Second, time.Millisecond)
+		app.Shutdown("test")
+		require.ErrorAs(t, app.Wait(), new(OptionalTaskError))
+	})
+}
+
+func TestOptionalTaskRollback(t *testing.T) {
+	t.Parallel()
+	errInit := errors.New("init failed")
+	err := New().WithDefaultValues().
+		Register(task.New(
+			initRunner{init: func(_ context.Context) error {
+				return errTest
+			}},
+			task.WithName("opt"),
+			task.WithCriticality(task.Optional),
+		)).
+		Register(initRunner{init: func(_ context.Context) error {
+			return errInit
+		}}).
+		Run(t.Context())
+	require.ErrorIs(t, err, errInit)
+	require.ErrorIs(t, err, errTest)
+	var optErr OptionalTaskError
+	require.ErrorAs(t, err, &optErr)
+	require.Equal(t, 1, optErr.Failure)
+	require.Equal(t, OutcomeInitFailure, OutcomeOf(err))
+}
+
+func TestOptionalTaskFailures(t *testing.T) {
+	t.Parallel()
+	errInit, errRun := errors.New("init failed"), errors.New("run failed")
+	var inits, runs atomic.Int32
+	app := New().WithDefaultValues().
+		WithOptionalTaskBackoff(task.Backoff{InitialBackoff: time.Microsecond, MaxBackoff: time.Microsecond}).
+		Register(funcRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})).
+		Register(task.New(
+			initRunner{
+				init: func(_ context.Context) error {
+					if inits.Add(1) == 1 {
+						return errInit
+					}
+					return nil
+				},
+				run: func(_ context.Context) error {
+					runs.Add(1)
+					return errRun
+				},
+			},
+			task.WithName("opt"),
+			task.WithCriticality(task.Optional),
+		)).
+		Start(t.Context())
+
+	require.Eventually(t, func() bool {
+		return runs.Load() >= 5
+	}, time.Second, time.Millisecond)
+	degraded := app.Degraded()
+	require.Len(t, degraded, 1)
+	require.Equal(t, PhaseRun, degraded[0].Phase)
+	require.ErrorIs(t, degraded[0].Err, errRun)
+
+	app.Shutdown("test")
+	err := app.Wait()
+	for taskErr := range TaskErrors(err) {
+		require.Equal(t, "opt", taskErr.Task)
+	}
+	var (
+		layerErrs []LayerError
+		optErrs   []OptionalTaskError
+	)
+	for _, err := range err.(RunError).Inner.(interface{ Unwrap() []error }).Unwrap() {
+		var layerErr LayerError
+		require.ErrorAs(t, err, &layerErr)
+		layerErrs = append(layerErrs, layerErr)
+		var optErr OptionalTaskError
+		require.ErrorAs(t, err, &optErr)
+		optErrs = append(optErrs, optErr)
+	}
+	require.Len(t, optErrs, 2)
+	require.Equal(t, 1, optErrs[0].Failure)
+	require.Equal(t, PhaseInit, layerErrs[0].Phase)
+	require.ErrorIs(t, optErrs[0], errInit)
+	require.GreaterOrEqual(t, optErrs[1].Failure, 6)
+	require.Equal(t, PhaseRun, layerErrs[1].Phase)
+	require.ErrorIs(t, optErrs[1], errRun)
+	require.Equal(t, OutcomeDegraded, OutcomeOf(err))
+}
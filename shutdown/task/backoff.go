@@ -0,0 +1,44 @@
+package task
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff configures exponential backoff with jitter between attempts.
+// Zero values of fields are replaced with defaults.
+type Backoff struct {
+	InitialBackoff time.Duration // default: 100ms
+	MaxBackoff     time.Duration // default: 30s
+	Multiplier     float64       // default: 2
+	Jitter         float64       // fraction of backoff randomly added or subtracted; default: 0
+}
+
+// Delay returns delay before the n-th attempt after the first one.
+func (b Backoff) Delay(n int) time.Duration {
+	initial := b.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	maxBackoff := b.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	res := float64(initial)
+	for range n - 1 {
+		res *= multiplier
+		if res >= float64(maxBackoff) {
+			res = float64(maxBackoff)
+			break
+		}
+	}
+	if b.Jitter > 0 {
+		res += res * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(res)
+}
@@ -0,0 +1,19 @@
+package task
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	b := Backoff{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+	require.Equal(t, time.Second, b.Delay(1))
+	require.Equal(t, 2*time.Second, b.Delay(2))
+	require.Equal(t, 4*time.Second, b.Delay(3))
+	require.Equal(t, 5*time.Second, b.Delay(4))
+}
@@ -0,0 +1,82 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// PanicError reports a panic recovered in a runner method.
+// Task recovers panics in Init, Run, Stop, Close and CheckHealth of its runner and returns them
+// as PanicError wrapped into RunError, so they are handled as any other error.
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack of the panicking goroutine
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (e PanicError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("panic", fmt.Sprint(e.Value)),
+		slog.String("stack", string(e.Stack)),
+	)
+}
+
+func (e PanicError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Panic string `json:"panic"`
+		Stack string `json:"stack"`
+	}{
+		Panic: fmt.Sprint(e.Value),
+		Stack: string(e.Stack),
+	})
+}
+
+// recoverPanic converts recovered panic to PanicError stored in err.
+// It must be deferred directly.
+func recoverPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = PanicError{
+			Value: v,
+			Stack: debug.Stack(),
+		}
+	}
+}
+
+func callInit(ctx context.Context, i Initer) (err error) {
+	defer recoverPanic(&err)
+	return i.Init(ctx)
+}
+
+func callRun(ctx context.Context, r Runner) (err error) {
+	defer recoverPanic(&err)
+	return r.Run(ctx)
+}
+
+func callStop(ctx context.Context, s Stopper) (err error) {
+	defer recoverPanic(&err)
+	return s.Stop(ctx)
+}
+
+func callClose(ctx context.Context, c Closer) (err error) {
+	defer recoverPanic(&err)
+	return c.Close(ctx)
+}
+
+func callCheckHealth(ctx context.Context, c HealthChecker) (err error) {
+	defer recoverPanic(&err)
+	return c.CheckHealth(ctx)
+}
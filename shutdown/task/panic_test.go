@@ -0,0 +1,62 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type panickingRunner struct {
+	value any
+}
+
+func (r panickingRunner) Init(_ context.Context) error {
+	panic(r.value)
+}
+
+func (r panickingRunner) Run(_ context.Context) error {
+	panic(r.value)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	t.Parallel()
+	rTask := New(panickingRunner{value: "boom"}, WithName("panicking"))
+
+	err := rTask.Run(t.Context())
+	var panicErr PanicError
+	require.ErrorAs(t, err, &panicErr)
+	require.Equal(t, "boom", panicErr.Value)
+	require.Contains(t, string(panicErr.Stack), "panickingRunner.Run")
+	require.EqualError(t, err, `run task "panicking": panic: boom`)
+
+	data, err := json.Marshal(err)
+	require.NoError(t, err)
+	var logged struct {
+		Error struct {
+			Panic string `json:"panic"`
+			Stack string `json:"stack"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(data, &logged))
+	require.Equal(t, "boom", logged.Error.Panic)
+	require.Contains(t, logged.Error.Stack, "panickingRunner.Run")
+
+	errPanic := errors.New("error value")
+	err = New(panickingRunner{value: errPanic}).Init(t.Context())
+	require.ErrorIs(t, err, errPanic)
+}
+
+func TestPanicNotRetried(t *testing.T) {
+	t.Parallel()
+	attempts := 0
+	rTask := New(panickingRunner{value: "boom"}, WithInitRetry(InitRetryPolicy{
+		Retryable: func(error) bool {
+			attempts++
+			return true
+		},
+	}))
+	require.ErrorAs(t, rTask.Init(t.Context()), new(PanicError))
+	require.Zero(t, attempts)
+}
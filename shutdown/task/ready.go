@@ -0,0 +1,26 @@
+package task
+
+import (
+	"context"
+)
+
+// ReadyFunc signals that the runner is ready to serve, e.g. after its listener is bound.
+// Only the first call has effect.
+type ReadyFunc func()
+
+type readyKey struct{}
+
+// ContextWithReadyFunc returns context carrying ready, see ReadyFuncFromContext.
+func ContextWithReadyFunc(ctx context.Context, ready ReadyFunc) context.Context {
+	return context.WithValue(ctx, readyKey{}, ready)
+}
+
+// ReadyFuncFromContext returns ReadyFunc provided to Run context.
+// Runner might call it from Run to signal its readiness to layers awaiting it.
+// If the context has no ReadyFunc, returned function does nothing.
+func ReadyFuncFromContext(ctx context.Context) ReadyFunc {
+	if ready, ok := ctx.Value(readyKey{}).(ReadyFunc); ok {
+		return ready
+	}
+	return func() {}
+}
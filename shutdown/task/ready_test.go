@@ -0,0 +1,26 @@
+package task
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type readyRunner struct{}
+
+func (readyRunner) Run(ctx context.Context) error {
+	ReadyFuncFromContext(ctx)()
+	return nil
+}
+
+func TestReadyFunc(t *testing.T) {
+	t.Parallel()
+	ReadyFuncFromContext(t.Context())() // no-op without ReadyFunc
+
+	var ready bool
+	ctx := ContextWithReadyFunc(t.Context(), func() {
+		ready = true
+	})
+	require.NoError(t, New(readyRunner{}).Run(ctx))
+	require.True(t, ready)
+}
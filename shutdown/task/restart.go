@@ -0,0 +1,144 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// RestartPolicy configures restarting of a task which Run returns an error.
+// Zero values of fields are replaced with defaults.
+type RestartPolicy struct {
+	Backoff
+	// MaxRestarts limits the number of restarts within Window.
+	// When exceeded, Run returns RestartLimitError. Default: unlimited.
+	MaxRestarts int
+	// Window is a sliding time window MaxRestarts is counted within. Default: the whole task lifetime.
+	Window time.Duration
+	// OnRestart is called with the number of the restart and the error causing it before waiting for backoff.
+	OnRestart func(restart int, err error)
+}
+
+// keptRestartFailures is the number of the most recent failures kept to compute backoff
+// if the number of restarts is unlimited.
+const keptRestartFailures = 10
+
+// RestartLimitError reports that the task has exceeded the number of restarts allowed by its RestartPolicy.
+// It is never ignored, even for fallible background tasks.
+type RestartLimitError struct {
+	Errs []error // errors of the runs failed within the restart window
+}
+
+func (e RestartLimitError) Error() string {
+	return fmt.Sprintf("restart limit exceeded after %d failures, last error: %s", len(e.Errs), e.Errs[len(e.Errs)-1].Error())
+}
+
+func (e RestartLimitError) Unwrap() []error {
+	return e.Errs
+}
+
+func (e RestartLimitError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("failures", len(e.Errs)),
+		slog.Any("errors", errorValues(e.Errs)),
+	)
+}
+
+func (e RestartLimitError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Failures int   `json:"failures"`
+		Errors   []any `json:"errors"`
+	}{
+		Failures: len(e.Errs),
+		Errors:   errorValues(e.Errs),
+	})
+}
+
+// RestartFunc is notified about each restart of a task with the number of the restart and the error causing it.
+type RestartFunc func(restart int, err error)
+
+type restartKey struct{}
+
+// ContextWithRestartFunc returns context carrying restart, which is called by Run of a task
+// with RestartPolicy on each restart alongside RestartPolicy.OnRestart.
+func ContextWithRestartFunc(ctx context.Context, restart RestartFunc) context.Context {
+	return context.WithValue(ctx, restartKey{}, restart)
+}
+
+// WithRestartPolicy makes task restart when its Run returns an error.
+// Task is not restarted when Run returns nil or its context is cancelled.
+// Restarts are reported to RestartPolicy.OnRestart and RestartFunc, see ContextWithRestartFunc,
+// while Run returns the result of the last run only.
+func WithRestartPolicy(policy RestartPolicy) func(*Task) {
+	return func(task *Task) {
+		task.restartPolicy.Set(policy)
+	}
+}
+
+// restartFailure is a failed run of a task causing its restart.
+type restartFailure struct {
+	at  time.Time
+	err error
+}
+
+func failureErrs(failures []restartFailure) []error {
+	errs := make([]error, 0, len(failures))
+	for _, f := range failures {
+		errs = append(errs, f.err)
+	}
+	return errs
+}
+
+// runWithRestarts runs the task restarting it according to policy and returns the result of the last run.
+// Returns nil if the task is stopped while waiting for restart.
+// Only failures that matter for the restart limit and backoff are kept, so memory use is bounded
+// even if the task is restarted indefinitely.
+func (t Task) runWithRestarts(ctx context.Context, policy RestartPolicy) error {
+	onRestart, _ := ctx.Value(restartKey{}).(RestartFunc)
+	limit := policy.MaxRestarts + 1
+	if policy.MaxRestarts <= 0 {
+		limit = keptRestartFailures
+	}
+	var (
+		restarts int
+		failures []restartFailure // within window, at most limit
+	)
+	for {
+		err := callRun(ctx, t.runner)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		now := time.Now()
+		if policy.Window > 0 {
+			failures = slices.DeleteFunc(failures, func(f restartFailure) bool {
+				return now.Sub(f.at) > policy.Window
+			})
+		}
+		failures = append(failures, restartFailure{at: now, err: err})
+		if policy.MaxRestarts > 0 && len(failures) > policy.MaxRestarts {
+			return RestartLimitError{Errs: failureErrs(failures)}
+		}
+		if len(failures) > limit {
+			failures = slices.Delete(failures, 0, len(failures)-limit)
+		}
+
+		restarts++
+		if policy.OnRestart != nil {
+			policy.OnRestart(restarts, err)
+		}
+		if onRestart != nil {
+			onRestart(restarts, err)
+		}
+		timer := time.NewTimer(policy.Delay(len(failures)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
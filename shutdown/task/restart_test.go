@@ -0,0 +1,77 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type flakyRunner struct {
+	failures int
+	runs     int
+}
+
+func (r *flakyRunner) Run(_ context.Context) error {
+	r.runs++
+	if r.runs <= r.failures {
+		return errors.New("flaky")
+	}
+	return nil
+}
+
+func TestRestartPolicy(t *testing.T) {
+	t.Parallel()
+	var restarts []int
+	r := flakyRunner{failures: 3}
+	rTask := New(&r, WithRestartPolicy(RestartPolicy{
+		Backoff: Backoff{
+			InitialBackoff: time.Millisecond,
+			Jitter:         0.5,
+		},
+		MaxRestarts: 3,
+		OnRestart: func(restart int, err error) {
+			restarts = append(restarts, restart)
+		},
+	}))
+	require.NoError(t, rTask.Run(t.Context()))
+	require.Equal(t, 4, r.runs)
+	require.Equal(t, []int{1, 2, 3}, restarts)
+}
+
+func TestRestartStopped(t *testing.T) {
+	t.Parallel()
+	r := flakyRunner{failures: 50}
+	var restarts []int
+	ctx, cancel := context.WithCancel(t.Context())
+	ctx = ContextWithRestartFunc(ctx, func(restart int, err error) {
+		restarts = append(restarts, restart)
+		if restart == 20 {
+			cancel()
+		}
+	})
+	rTask := New(&r, WithRestartPolicy(RestartPolicy{
+		Backoff: Backoff{InitialBackoff: time.Microsecond, MaxBackoff: time.Microsecond},
+	}))
+	err := rTask.Run(ctx)
+	if err != nil { // the last run might have started before cancellation
+		require.EqualError(t, errors.Unwrap(err), "flaky")
+	}
+	require.Len(t, restarts, 20)
+}
+
+func TestRestartLimit(t *testing.T) {
+	t.Parallel()
+	r := flakyRunner{failures: 10}
+	rTask := New(&r, WithRestartPolicy(RestartPolicy{
+		Backoff:     Backoff{InitialBackoff: time.Millisecond},
+		MaxRestarts: 2,
+		Window:      time.Minute,
+	}))
+	err := rTask.Run(t.Context())
+	var limitErr RestartLimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Len(t, limitErr.Errs, 3)
+	require.Equal(t, 3, r.runs)
+}
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// InitRetryPolicy configures retrying of a task which Init returns an error.
+// Zero values of fields are replaced with defaults.
+type InitRetryPolicy struct {
+	Backoff
+	MaxAttempts int                  // default: unlimited, until Init context is cancelled
+	Retryable   func(err error) bool // reports whether Init should be retried after err; default: always; panics are never retried
+	Logger      *slog.Logger         // logger of failed attempts; default: slog.Default()
+}
+
+// WithInitRetry makes task retry Init when it returns a retryable error.
+// Each failed attempt is logged with the task name. When attempts are exhausted
+// or a non-retryable error is returned, the last error is returned.
+// When Init context is cancelled, the context error is returned joined with the last error.
+func WithInitRetry(policy InitRetryPolicy) func(*Task) {
+	return func(task *Task) {
+		task.initRetry.Set(policy)
+	}
+}
+
+// initWithRetries runs Init of initer retrying it according to policy.
+func (t Task) initWithRetries(ctx context.Context, initer Initer, policy InitRetryPolicy) error {
+	log := policy.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	log = log.With(slog.String("task", t.name.Or(t.TypeName())))
+
+	for attempt := 1; ; attempt++ {
+		err := callInit(ctx, initer)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return initCancelled(ctx, log, attempt, err)
+		}
+		if errors.As(err, new(PanicError)) ||
+			(policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts) ||
+			(policy.Retryable != nil && !policy.Retryable(err)) {
+			log.LogAttrs(ctx, slog.LevelError, "Task init failed",
+				slog.Int("attempt", attempt),
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		log.LogAttrs(ctx, slog.LevelWarn, "Task init attempt failed, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", delay),
+			slog.String("error", err.Error()),
+		)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return initCancelled(ctx, log, attempt, err)
+		}
+	}
+}
+
+// initCancelled logs Init cancelled after the attempt failed with err and returns the context error joined with err,
+// so that cancellation is not mistaken for a failure of the task.
+func initCancelled(ctx context.Context, log *slog.Logger, attempt int, err error) error {
+	log.LogAttrs(ctx, slog.LevelInfo, "Task init cancelled",
+		slog.Int("attempt", attempt),
+		slog.String("error", err.Error()),
+	)
+	return errors.Join(ctx.Err(), err)
+}
@@ -0,0 +1,105 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errFatal = errors.New("fatal")
+
+type flakyIniter struct {
+	simpleRunner
+	failures []error
+	attempts int
+}
+
+func (r *flakyIniter) Init(_ context.Context) error {
+	r.attempts++
+	if r.attempts <= len(r.failures) {
+		return r.failures[r.attempts-1]
+	}
+	return nil
+}
+
+func TestInitRetry(t *testing.T) {
+	t.Parallel()
+	flaky := errors.New("flaky")
+	testCases := []struct {
+		name         string
+		failures     []error
+		maxAttempts  int
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			name:         "succeeds after retries",
+			failures:     []error{flaky, flaky},
+			wantAttempts: 3,
+		},
+		{
+			name:         "attempts exhausted",
+			failures:     []error{flaky, flaky, flaky},
+			maxAttempts:  2,
+			wantErr:      flaky,
+			wantAttempts: 2,
+		},
+		{
+			name:         "non-retryable error",
+			failures:     []error{flaky, errFatal, flaky},
+			wantErr:      errFatal,
+			wantAttempts: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			r := flakyIniter{failures: tc.failures}
+			rTask := New(&r, WithName("db"), WithInitRetry(InitRetryPolicy{
+				Backoff:     Backoff{InitialBackoff: time.Millisecond},
+				MaxAttempts: tc.maxAttempts,
+				Retryable: func(err error) bool {
+					return !errors.Is(err, errFatal)
+				},
+				Logger: slog.New(slog.NewTextHandler(&buf, nil)),
+			}))
+			err := rTask.Init(t.Context())
+			failedAttempts := tc.wantAttempts - 1
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				failedAttempts++
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.wantAttempts, r.attempts)
+			require.Equal(t, failedAttempts, strings.Count(buf.String(), "task=db attempt="))
+		})
+	}
+}
+
+func TestInitRetryCancelled(t *testing.T) {
+	t.Parallel()
+	flaky := errors.New("flaky")
+	var buf bytes.Buffer
+	ctx, cancel := context.WithCancel(t.Context())
+	r := flakyIniter{failures: []error{flaky, flaky, flaky}}
+	rTask := New(&r, WithInitRetry(InitRetryPolicy{
+		Backoff: Backoff{InitialBackoff: time.Hour},
+		Retryable: func(_ error) bool {
+			cancel() // cancelled while waiting for backoff
+			return true
+		},
+		Logger: slog.New(slog.NewTextHandler(&buf, nil)),
+	}))
+	err := rTask.Init(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, flaky)
+	require.Equal(t, 1, r.attempts)
+	require.Contains(t, buf.String(), "level=INFO msg=\"Task init cancelled\"")
+}
@@ -2,8 +2,13 @@ package task
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/oomamontov/grace/pkg/optional"
+	"log/slog"
+	"strings"
+	"time"
 )
 
 type Runner interface {
@@ -14,15 +19,42 @@ type Initer interface {
 	Init(ctx context.Context) error
 }
 
+// Closer releases resources acquired by Init.
+// It is called only if the application fails to start after the runner has been successfully initialized,
+// so Run is never called in this case.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Stopper stops runner explicitly, e.g. runner wrapping blocking Serve and separate Shutdown methods.
+// Stop is called on shutdown with context limited by stop timeouts of the layer and the application and by
+// the task stop timeout of the application: 30s with Config.WithDefaultValues, unlimited otherwise.
+// Run context is cancelled only after Stop returns. Run should return after Stop is called.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// HealthChecker reports health of running runner.
+// CheckHealth is called periodically during Run stage with context limited by health check timeout
+// and should return an error if the runner is unhealthy.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
 const (
-	ActionInit = "init"
-	ActionRun  = "run"
+	ActionInit        = "init"
+	ActionRun         = "run"
+	ActionStop        = "stop"
+	ActionClose       = "close"
+	ActionCheckHealth = "check health of"
 )
 
+// RunError reports an error of a task action.
 type RunError struct {
-	Name   optional.Value[string]
-	Action string
-	Inner  error
+	Name     optional.Value[string] // task name provided by WithName or its runner type name, see Task.TypeName
+	Action   string
+	Duration time.Duration // time since the action started
+	Inner    error
 }
 
 func (e RunError) Error() string {
@@ -36,9 +68,101 @@ func (e RunError) Unwrap() error {
 	return e.Inner
 }
 
+func (e RunError) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 4)
+	if name, ok := e.Name.Get(); ok {
+		attrs = append(attrs, slog.String("task", name))
+	}
+	attrs = append(attrs,
+		slog.String("action", e.Action),
+		slog.Duration("duration", e.Duration),
+		slog.Any("error", errorValue(e.Inner)),
+	)
+	return slog.GroupValue(attrs...)
+}
+
+func (e RunError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Task     string        `json:"task,omitempty"`
+		Action   string        `json:"action"`
+		Duration time.Duration `json:"duration"`
+		Error    any           `json:"error"`
+	}{
+		Task:     e.Name.GetOrDefault(),
+		Action:   e.Action,
+		Duration: e.Duration,
+		Error:    errorValue(e.Inner),
+	})
+}
+
+// errorValue returns representation of err for structured logging and JSON:
+// err itself if it implements both slog.LogValuer and json.Marshaler, representations of joined errors
+// or error message otherwise.
+func errorValue(err error) any {
+	switch e := err.(type) {
+	case interface {
+		slog.LogValuer
+		json.Marshaler
+	}:
+		return e
+	case interface{ Unwrap() []error }:
+		return errorValues(e.Unwrap())
+	default:
+		return err.Error()
+	}
+}
+
+// errorValues returns representations of errs, see errorValue.
+func errorValues(errs []error) []any {
+	res := make([]any, 0, len(errs))
+	for _, err := range errs {
+		res = append(res, errorValue(err))
+	}
+	return res
+}
+
+// InitTimeoutError reports that task Init has not returned within timeout provided by WithInitTimeout.
+type InitTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e InitTimeoutError) Error() string {
+	return fmt.Sprintf("init timeout %s exceeded", e.Timeout)
+}
+
+func (e InitTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Criticality defines how failure of the task affects the application.
+type Criticality int
+
+const (
+	// Critical task failure stops the application.
+	Critical Criticality = iota
+	// Optional task failure degrades the application, which keeps running while the task is retried.
+	Optional
+)
+
+func (c Criticality) String() string {
+	switch c {
+	case Critical:
+		return "critical"
+	case Optional:
+		return "optional"
+	default:
+		return fmt.Sprintf("Criticality(%d)", int(c))
+	}
+}
+
 type Task struct {
-	name   optional.Value[string]
-	runner Runner
+	name          optional.Value[string]
+	stopPriority  int
+	criticality   Criticality
+	restartPolicy optional.Value[RestartPolicy]
+	initRetry     optional.Value[InitRetryPolicy]
+	initTimeout   optional.Value[time.Duration]
+	runner        Runner
 }
 
 func WithName(name string) func(*Task) {
@@ -47,6 +171,30 @@ func WithName(name string) func(*Task) {
 	}
 }
 
+// WithStopPriority sets order of stopping the task within its layer.
+// Tasks with greater priority are stopped first, and the next tasks are stopped only after they return.
+// Default priority is 0.
+func WithStopPriority(priority int) func(*Task) {
+	return func(task *Task) {
+		task.stopPriority = priority
+	}
+}
+
+// WithCriticality sets how failure of the task affects the application. Default criticality is Critical.
+func WithCriticality(criticality Criticality) func(*Task) {
+	return func(task *Task) {
+		task.criticality = criticality
+	}
+}
+
+// WithInitTimeout limits time of task Init, including retries.
+// When exceeded, Init returns InitTimeoutError without waiting for the runner Init to return.
+func WithInitTimeout(timeout time.Duration) func(*Task) {
+	return func(task *Task) {
+		task.initTimeout.Set(timeout)
+	}
+}
+
 func New(runner Runner, opts ...func(*Task)) Task {
 	res := Task{runner: runner}
 	for _, opt := range opts {
@@ -55,25 +203,135 @@ func New(runner Runner, opts ...func(*Task)) Task {
 	return res
 }
 
+// With returns copy of the task with opts applied on top of options it was created with.
+func (t Task) With(opts ...func(*Task)) Task {
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+// Name returns task name provided by WithName option.
+func (t Task) Name() optional.Value[string] {
+	return t.name
+}
+
+// StopPriority returns task stop priority provided by WithStopPriority option.
+func (t Task) StopPriority() int {
+	return t.stopPriority
+}
+
+// Criticality returns task criticality provided by WithCriticality option.
+func (t Task) Criticality() Criticality {
+	return t.criticality
+}
+
+// TypeName returns name of the runner type, e.g. "kv.Storage" for *kv.Storage runner.
+// It is used as the task name in errors if the name is not provided by WithName option.
+func (t Task) TypeName() string {
+	return strings.TrimLeft(fmt.Sprintf("%T", t.runner), "*")
+}
+
+// runError returns err of the action started at start wrapped into RunError.
+func (t Task) runError(action string, start time.Time, err error) RunError {
+	return RunError{
+		Name:     optional.New(t.name.Or(t.TypeName())),
+		Action:   action,
+		Duration: time.Since(start),
+		Inner:    err,
+	}
+}
+
 func (t Task) Init(ctx context.Context) error {
-	if i, ok := t.runner.(Initer); ok {
-		if err := i.Init(ctx); err != nil {
-			return RunError{
-				Name:   t.name,
-				Action: ActionInit,
-				Inner:  err,
-			}
-		}
+	i, ok := t.runner.(Initer)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	var err error
+	if timeout, ok := t.initTimeout.Get(); ok {
+		err = t.initWithTimeout(ctx, i, timeout)
+	} else {
+		err = t.init(ctx, i)
+	}
+	if err != nil {
+		return t.runError(ActionInit, start, err)
 	}
 	return nil
 }
 
+// init runs Init of initer, retrying it if configured.
+func (t Task) init(ctx context.Context, i Initer) error {
+	if policy, ok := t.initRetry.Get(); ok {
+		return t.initWithRetries(ctx, i, policy)
+	}
+	return callInit(ctx, i)
+}
+
+// initWithTimeout runs Init of initer and returns InitTimeoutError if it does not return within timeout.
+// Init ignoring context cancellation is not waited for after timeout.
+func (t Task) initWithTimeout(ctx context.Context, i Initer, timeout time.Duration) error {
+	timeoutErr := InitTimeoutError{Timeout: timeout}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, timeoutErr)
+	defer cancel()
+	res := make(chan error, 1)
+	go func() {
+		res <- t.init(ctx, i)
+	}()
+	select {
+	case err := <-res:
+		if err != nil && errors.Is(context.Cause(ctx), timeoutErr) {
+			return timeoutErr
+		}
+		return err
+	case <-ctx.Done():
+		if errors.Is(context.Cause(ctx), timeoutErr) {
+			return timeoutErr
+		}
+		return <-res
+	}
+}
+
 func (t Task) Run(ctx context.Context) error {
-	if err := t.runner.Run(ctx); err != nil {
-		return RunError{
-			Name:   t.name,
-			Action: ActionRun,
-			Inner:  err,
+	start := time.Now()
+	var err error
+	if policy, ok := t.restartPolicy.Get(); ok {
+		err = t.runWithRestarts(ctx, policy)
+	} else {
+		err = callRun(ctx, t.runner)
+	}
+	if err != nil {
+		return t.runError(ActionRun, start, err)
+	}
+	return nil
+}
+
+func (t Task) Stop(ctx context.Context) error {
+	if s, ok := t.runner.(Stopper); ok {
+		start := time.Now()
+		if err := callStop(ctx, s); err != nil {
+			return t.runError(ActionStop, start, err)
+		}
+	}
+	return nil
+}
+
+func (t Task) Close(ctx context.Context) error {
+	if c, ok := t.runner.(Closer); ok {
+		start := time.Now()
+		if err := callClose(ctx, c); err != nil {
+			return t.runError(ActionClose, start, err)
+		}
+	}
+	return nil
+}
+
+// CheckHealth calls CheckHealth of the runner if it implements HealthChecker, otherwise the task is considered healthy.
+func (t Task) CheckHealth(ctx context.Context) error {
+	if c, ok := t.runner.(HealthChecker); ok {
+		start := time.Now()
+		if err := callCheckHealth(ctx, c); err != nil {
+			return t.runError(ActionCheckHealth, start, err)
 		}
 	}
 	return nil
@@ -2,8 +2,12 @@ package task
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 type simpleRunner struct {
@@ -25,6 +29,34 @@ func (r *simpleIniterRunner) Init(_ context.Context) error {
 	return nil
 }
 
+type simpleCloserRunner struct {
+	simpleIniterRunner
+	closed bool
+}
+
+func (r *simpleCloserRunner) Close(_ context.Context) error {
+	r.closed = true
+	return nil
+}
+
+type simpleStopperRunner struct {
+	simpleRunner
+	stopped bool
+}
+
+func (r *simpleStopperRunner) Stop(_ context.Context) error {
+	r.stopped = true
+	return nil
+}
+
+type unhealthyRunner struct {
+	simpleRunner
+}
+
+func (r *unhealthyRunner) CheckHealth(_ context.Context) error {
+	return errors.New("unhealthy")
+}
+
 func TestRunner(t *testing.T) {
 	t.Parallel()
 	var r simpleRunner
@@ -49,3 +81,88 @@ func TestIniter(t *testing.T) {
 	require.True(t, r.initialized)
 	require.True(t, r.ran)
 }
+
+func TestCloser(t *testing.T) {
+	t.Parallel()
+	var r simpleCloserRunner
+	rTask := New(&r)
+	require.NoError(t, rTask.Init(t.Context()))
+	require.True(t, r.initialized)
+	require.False(t, r.closed)
+	require.NoError(t, rTask.Close(t.Context()))
+	require.True(t, r.closed)
+	require.False(t, r.ran)
+}
+
+func TestStopper(t *testing.T) {
+	t.Parallel()
+	var r simpleStopperRunner
+	rTask := New(&r)
+	require.NoError(t, rTask.Run(t.Context()))
+	require.False(t, r.stopped)
+	require.NoError(t, rTask.Stop(t.Context()))
+	require.True(t, r.stopped)
+}
+
+func TestHealthChecker(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, New(&simpleRunner{}).CheckHealth(t.Context()))
+	err := New(&unhealthyRunner{}, WithName("db")).CheckHealth(t.Context())
+	require.EqualError(t, err, `check health of task "db": unhealthy`)
+}
+
+type hangingIniter struct {
+	simpleRunner
+	release chan struct{}
+}
+
+func (r *hangingIniter) Init(_ context.Context) error {
+	<-r.release
+	return nil
+}
+
+func TestInitTimeout(t *testing.T) {
+	t.Parallel()
+	r := hangingIniter{release: make(chan struct{})}
+	defer close(r.release)
+	rTask := New(&r, WithInitTimeout(10*time.Millisecond))
+	err := rTask.Init(t.Context())
+	require.ErrorIs(t, err, InitTimeoutError{Timeout: 10 * time.Millisecond})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRunErrorModel(t *testing.T) {
+	t.Parallel()
+	rTask := New(&unhealthyRunner{})
+	require.Equal(t, "task.unhealthyRunner", rTask.TypeName())
+
+	err := rTask.CheckHealth(t.Context())
+	require.EqualError(t, err, `check health of task "task.unhealthyRunner": unhealthy`)
+	var runErr RunError
+	require.ErrorAs(t, err, &runErr)
+	require.Equal(t, "task.unhealthyRunner", runErr.Name.ShouldGet())
+
+	data, err := json.Marshal(runErr)
+	require.NoError(t, err)
+	require.JSONEq(t, fmt.Sprintf(
+		`{"task":"task.unhealthyRunner","action":"check health of","duration":%d,"error":"unhealthy"}`,
+		runErr.Duration,
+	), string(data))
+	require.Equal(t, "unhealthy", runErr.LogValue().Group()[3].Value.String())
+}
+
+func TestCriticality(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, Critical, New(&simpleRunner{}).Criticality())
+	require.Equal(t, Optional, New(&simpleRunner{}, WithCriticality(Optional)).Criticality())
+	require.Equal(t, "optional", Optional.String())
+}
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+	base := New(&simpleRunner{}, WithStopPriority(1))
+	named := base.With(WithName("db"))
+	require.Equal(t, "db", named.Name().ShouldGet())
+	require.Equal(t, 1, named.StopPriority())
+	require.False(t, base.Name().IsSet())
+}